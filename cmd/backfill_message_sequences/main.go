@@ -0,0 +1,45 @@
+// Command backfill_message_sequences assigns per-conversation sequence numbers to
+// messages stored without one and converts their timestamps to milliseconds. Messages
+// that already have a sequence keep it; the rest are numbered above each
+// conversation's counter in the order they were written. Run it against each
+// environment after deploying. Replies the AI service saves without a sequence are
+// ordered by timestamp when listed, so they don't need another run.
+package main
+
+import (
+	"context"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/mongodb"
+	"os"
+
+	"github.com/joho/godotenv"
+	"go.uber.org/zap"
+)
+
+func main() {
+	if err := logger.Init(os.Getenv("ENV") == "development", logger.InfoLevel); err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	defer logger.Sync()
+
+	if os.Getenv("ENV") != "production" {
+		if err := godotenv.Load(); err != nil {
+			logger.Get().Error("Warning: .env file not found")
+		}
+	}
+
+	if err := mongodb.InitMongoDB(); err != nil {
+		logger.Get().Fatal("Failed to initialize MongoDB", zap.Error(err))
+	}
+	defer mongodb.CloseMongoDB()
+
+	updated, err := mongodb.BackfillMessageSequences(context.Background())
+	if err != nil {
+		logger.Get().Fatal("Failed to backfill message sequences",
+			zap.Int("messages_updated", updated),
+			zap.Error(err))
+	}
+
+	logger.Get().Info("Message sequence backfill complete",
+		zap.Int("messages_updated", updated))
+}
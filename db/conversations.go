@@ -1,21 +1,82 @@
 package db
 
-import "finance-chatbot/api/models"
+import (
+	"encoding/base64"
+	"errors"
+	"finance-chatbot/api/models"
+	"fmt"
+	"strings"
+	"time"
 
-func CreateConversation(userID string, title string) (*models.Conversation, error) {
-	query := `
-		INSERT INTO conversations (user_id, title)
-		VALUES ($1, $2)
-		RETURNING id, title, user_id, created_at
-	`
-	item := &models.Conversation{}
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const conversationColumns = `id, user_id, created_at, title, COALESCE(last_message_at, created_at), parent_conversation_id, forked_from_sequence, archived, pinned, deleted_at,
+	ARRAY(SELECT tag_id::text FROM conversation_tags WHERE conversation_id = conversations.id),
+	ARRAY(SELECT folder_id::text FROM conversation_folders WHERE conversation_id = conversations.id)`
+
+const (
+	ConversationSortRecent  = "recent"
+	ConversationSortCreated = "created"
+
+	DefaultConversationPageSize = 20
+	MaxConversationPageSize     = 100
+)
 
-	err := DB.QueryRow(query, userID, title).Scan(
+// ConversationListParams controls paging, searching, filtering and sorting of a
+// user's conversations. Soft-deleted conversations are only listed when Deleted is
+// true. Archived conversations are hidden unless Archived is true, except in the
+// deleted list, which only filters on Archived when it is set. TagID and
+// FolderID restrict the list to conversations carrying that tag or filed in that folder.
+type ConversationListParams struct {
+	Cursor   string
+	Limit    int
+	Search   string
+	Sort     string
+	Archived *bool
+	Pinned   *bool
+	Deleted  bool
+	TagID    string
+	FolderID string
+}
+
+// ErrInvalidListParams is returned when a list cursor or sort option cannot be used
+var ErrInvalidListParams = errors.New("invalid list parameters")
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanConversation(row rowScanner) (*models.Conversation, error) {
+	item := &models.Conversation{}
+	err := row.Scan(
 		&item.ID,
-		&item.Title,
 		&item.UserID,
 		&item.CreatedAt,
+		&item.Title,
+		&item.LastMessageAt,
+		&item.ParentConversationID,
+		&item.ForkedFromSequence,
+		&item.Archived,
+		&item.Pinned,
+		&item.DeletedAt,
+		pq.Array(&item.TagIDs),
+		pq.Array(&item.FolderIDs),
 	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func CreateConversation(userID string, title string) (*models.Conversation, error) {
+	query := `
+		INSERT INTO conversations (user_id, title, last_message_at)
+		VALUES ($1, $2, NOW())
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, userID, title))
 	if err != nil {
 		return &models.Conversation{}, err
 	}
@@ -23,6 +84,22 @@ func CreateConversation(userID string, title string) (*models.Conversation, erro
 	return item, nil
 }
 
+// CreateForkedConversation creates a conversation that branches from parentID after
+// the message with the given sequence number
+func CreateForkedConversation(userID string, title string, parentID string, sequence int64) (*models.Conversation, error) {
+	query := `
+		INSERT INTO conversations (user_id, title, last_message_at, parent_conversation_id, forked_from_sequence)
+		VALUES ($1, $2, NOW(), $3, $4)
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, userID, title, parentID, sequence))
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
 func DeleteConversation(id string) error {
 	query := `
 		DELETE FROM conversations
@@ -38,17 +115,11 @@ func DeleteConversation(id string) error {
 
 func GetByID(id string) (*models.Conversation, error) {
 	query := `
-		SELECT id, user_id, created_at, title
+		SELECT ` + conversationColumns + `
 		FROM conversations
 		WHERE id = $1
 	`
-	item := &models.Conversation{}
-	err := DB.QueryRow(query, id).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.CreatedAt,
-		&item.Title,
-	)
+	item, err := scanConversation(DB.QueryRow(query, id))
 	if err != nil {
 		return nil, err
 	}
@@ -56,9 +127,25 @@ func GetByID(id string) (*models.Conversation, error) {
 	return item, nil
 }
 
+// IsConversationDeleted reports whether a conversation is in the trash. It returns
+// sql.ErrNoRows when the conversation doesn't exist, such as after it was purged.
+func IsConversationDeleted(id string) (bool, error) {
+	query := `
+		SELECT deleted_at IS NOT NULL
+		FROM conversations
+		WHERE id = $1
+	`
+	var deleted bool
+	if err := DB.QueryRow(query, id).Scan(&deleted); err != nil {
+		return false, err
+	}
+
+	return deleted, nil
+}
+
 func GetAllConversationsByUserID(userID string) ([]*models.Conversation, error) {
 	query := `
-		SELECT id, user_id, created_at, title
+		SELECT ` + conversationColumns + `
 		FROM conversations
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -72,20 +159,149 @@ func GetAllConversationsByUserID(userID string) ([]*models.Conversation, error)
 	defer rows.Close()
 
 	for rows.Next() {
-		item := &models.Conversation{}
-		err := rows.Scan(
-			&item.ID,
-			&item.UserID,
-			&item.CreatedAt,
-			&item.Title,
-		)
+		item, err := scanConversation(rows)
 		if err != nil {
 			return nil, err
 		}
 		items = append(items, item)
 	}
 
-	return items, nil
+	return items, rows.Err()
+}
+
+// ListConversationsByUserID returns one page of a user's conversations along with
+// the cursor for the next page. The next cursor is empty when there are no more pages.
+func ListConversationsByUserID(userID string, params ConversationListParams) ([]*models.Conversation, string, error) {
+	sortColumn := "COALESCE(last_message_at, created_at)"
+	if params.Sort == ConversationSortCreated {
+		sortColumn = "created_at"
+	} else if params.Sort != "" && params.Sort != ConversationSortRecent {
+		return nil, "", fmt.Errorf("%w: unknown sort %q", ErrInvalidListParams, params.Sort)
+	}
+
+	limit := params.Limit
+	if limit <= 0 {
+		limit = DefaultConversationPageSize
+	}
+	if limit > MaxConversationPageSize {
+		limit = MaxConversationPageSize
+	}
+
+	args := []any{userID}
+	conditions := []string{"user_id = $1"}
+
+	if params.Deleted {
+		conditions = append(conditions, "deleted_at IS NOT NULL")
+	} else {
+		conditions = append(conditions, "deleted_at IS NULL")
+	}
+
+	if params.Archived != nil || !params.Deleted {
+		archived := params.Archived != nil && *params.Archived
+		args = append(args, archived)
+		conditions = append(conditions, fmt.Sprintf("archived = $%d", len(args)))
+	}
+
+	if params.Pinned != nil {
+		args = append(args, *params.Pinned)
+		conditions = append(conditions, fmt.Sprintf("pinned = $%d", len(args)))
+	}
+
+	if params.TagID != "" {
+		if _, err := uuid.Parse(params.TagID); err != nil {
+			return nil, "", fmt.Errorf("%w: malformed tag_id", ErrInvalidListParams)
+		}
+		args = append(args, params.TagID)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM conversation_tags ct WHERE ct.conversation_id = conversations.id AND ct.tag_id = $%d)", len(args)))
+	}
+
+	if params.FolderID != "" {
+		if _, err := uuid.Parse(params.FolderID); err != nil {
+			return nil, "", fmt.Errorf("%w: malformed folder_id", ErrInvalidListParams)
+		}
+		args = append(args, params.FolderID)
+		conditions = append(conditions, fmt.Sprintf("EXISTS (SELECT 1 FROM conversation_folders cf WHERE cf.conversation_id = conversations.id AND cf.folder_id = $%d)", len(args)))
+	}
+
+	if search := strings.TrimSpace(params.Search); search != "" {
+		args = append(args, "%"+escapeLike(search)+"%")
+		conditions = append(conditions, fmt.Sprintf("title ILIKE $%d", len(args)))
+	}
+
+	if params.Cursor != "" {
+		cursorTime, cursorID, err := decodeConversationCursor(params.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		args = append(args, cursorTime, cursorID)
+		conditions = append(conditions, fmt.Sprintf("(%s, id) < ($%d, $%d)", sortColumn, len(args)-1, len(args)))
+	}
+
+	args = append(args, limit+1)
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM conversations
+		WHERE %s
+		ORDER BY %s DESC, id DESC
+		LIMIT $%d
+	`, conversationColumns, strings.Join(conditions, " AND "), sortColumn, len(args))
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rows.Close()
+
+	items := []*models.Conversation{}
+	for rows.Next() {
+		item, err := scanConversation(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var nextCursor string
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[len(items)-1]
+		sortTime := last.LastMessageAt
+		if params.Sort == ConversationSortCreated {
+			sortTime = last.CreatedAt
+		}
+		nextCursor = encodeConversationCursor(sortTime, last.ID)
+	}
+
+	return items, nextCursor, nil
+}
+
+// GetConversationTitlesByUserID maps each of a user's conversation IDs to its title
+func GetConversationTitlesByUserID(userID string) (map[string]string, error) {
+	query := `
+		SELECT id, title
+		FROM conversations
+		WHERE user_id = $1 AND deleted_at IS NULL
+	`
+	rows, err := DB.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	titles := map[string]string{}
+	for rows.Next() {
+		var id uuid.UUID
+		var title string
+		if err := rows.Scan(&id, &title); err != nil {
+			return nil, err
+		}
+		titles[id.String()] = title
+	}
+
+	return titles, rows.Err()
 }
 
 func UpdateConversation(id string, title string) (*models.Conversation, error) {
@@ -93,16 +309,9 @@ func UpdateConversation(id string, title string) (*models.Conversation, error) {
 		UPDATE conversations
 		SET title = $1
 		WHERE id = $2
-		RETURNING id, user_id, created_at, title
-	`
+		RETURNING ` + conversationColumns
 
-	item := &models.Conversation{}
-	err := DB.QueryRow(query, title, id).Scan(
-		&item.ID,
-		&item.UserID,
-		&item.CreatedAt,
-		&item.Title,
-	)
+	item, err := scanConversation(DB.QueryRow(query, title, id))
 	if err != nil {
 		return nil, err
 	}
@@ -110,6 +319,111 @@ func UpdateConversation(id string, title string) (*models.Conversation, error) {
 	return item, nil
 }
 
+func SetConversationArchived(id string, archived bool) (*models.Conversation, error) {
+	query := `
+		UPDATE conversations
+		SET archived = $1
+		WHERE id = $2
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, archived, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+func SetConversationPinned(id string, pinned bool) (*models.Conversation, error) {
+	query := `
+		UPDATE conversations
+		SET pinned = $1
+		WHERE id = $2
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, pinned, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// SoftDeleteConversation hides a conversation until it is restored or purged
+func SoftDeleteConversation(id string) (*models.Conversation, error) {
+	query := `
+		UPDATE conversations
+		SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, id))
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// RestoreConversation undoes a soft delete made after deletedAfter. It returns
+// sql.ErrNoRows when the conversation is not deleted or is past the retention window.
+func RestoreConversation(id string, deletedAfter time.Time) (*models.Conversation, error) {
+	query := `
+		UPDATE conversations
+		SET deleted_at = NULL
+		WHERE id = $1 AND deleted_at IS NOT NULL AND deleted_at > $2
+		RETURNING ` + conversationColumns
+
+	item, err := scanConversation(DB.QueryRow(query, id, deletedAfter))
+	if err != nil {
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// GetConversationIDsDeletedBefore returns up to limit soft-deleted conversations
+// that are due to be purged
+func GetConversationIDsDeletedBefore(cutoff time.Time, limit int) ([]string, error) {
+	query := `
+		SELECT id
+		FROM conversations
+		WHERE deleted_at IS NOT NULL AND deleted_at <= $1
+		ORDER BY deleted_at
+		LIMIT $2
+	`
+	rows, err := DB.Query(query, cutoff, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error listing conversations to purge: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning conversation id: %v", err)
+		}
+		ids = append(ids, id.String())
+	}
+
+	return ids, rows.Err()
+}
+
+// TouchConversation records that a message was just sent in the conversation
+func TouchConversation(id string) error {
+	query := `
+		UPDATE conversations
+		SET last_message_at = NOW()
+		WHERE id = $1
+	`
+	_, err := DB.Exec(query, id)
+	if err != nil {
+		return fmt.Errorf("error updating last_message_at for conversation %s: %v", id, err)
+	}
+	return nil
+}
+
 func DeleteConversationsByUserID(userId string) error {
 	query := `
 		DELETE FROM conversations
@@ -123,3 +437,37 @@ func DeleteConversationsByUserID(userId string) error {
 
 	return nil
 }
+
+func encodeConversationCursor(sortTime time.Time, id uuid.UUID) string {
+	raw := sortTime.UTC().Format(time.RFC3339Nano) + "|" + id.String()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeConversationCursor(cursor string) (time.Time, uuid.UUID, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListParams)
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w: malformed cursor", ErrInvalidListParams)
+	}
+
+	sortTime, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w: malformed cursor time", ErrInvalidListParams)
+	}
+
+	id, err := uuid.Parse(parts[1])
+	if err != nil {
+		return time.Time{}, uuid.Nil, fmt.Errorf("%w: malformed cursor id", ErrInvalidListParams)
+	}
+
+	return sortTime, id, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input is matched literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
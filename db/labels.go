@@ -0,0 +1,166 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"finance-chatbot/api/models"
+	"fmt"
+
+	"github.com/lib/pq"
+)
+
+const labelColumns = `id, user_id, name, created_at`
+
+// ErrDuplicateName is returned when a user already has a tag or folder with the same name
+var ErrDuplicateName = errors.New("name already in use")
+
+// LabelKind is one kind of user-defined label for conversations. Tags and folders
+// behave the same and differ only in their tables.
+type LabelKind struct {
+	// Noun names the kind in errors, such as "tag"
+	Noun string
+	// table holds the labels and linkTable attaches them to conversations through
+	// linkColumn. They are always constants from this package.
+	table      string
+	linkTable  string
+	linkColumn string
+}
+
+var (
+	Tags    = LabelKind{Noun: "tag", table: "tags", linkTable: "conversation_tags", linkColumn: "tag_id"}
+	Folders = LabelKind{Noun: "folder", table: "folders", linkTable: "conversation_folders", linkColumn: "folder_id"}
+)
+
+func scanLabel(row rowScanner) (*models.Label, error) {
+	item := &models.Label{}
+	if err := row.Scan(&item.ID, &item.UserID, &item.Name, &item.CreatedAt); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// isUniqueViolation reports whether err is a Postgres unique constraint violation
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+func (kind LabelKind) Create(userID string, name string) (*models.Label, error) {
+	query := `
+		INSERT INTO ` + kind.table + ` (user_id, name)
+		VALUES ($1, $2)
+		RETURNING ` + labelColumns
+
+	item, err := scanLabel(DB.QueryRow(query, userID, name))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateName
+		}
+		return nil, fmt.Errorf("error creating %s: %v", kind.Noun, err)
+	}
+	return item, nil
+}
+
+func (kind LabelKind) ListByUserID(userID string) ([]*models.Label, error) {
+	query := `
+		SELECT ` + labelColumns + `
+		FROM ` + kind.table + `
+		WHERE user_id = $1
+		ORDER BY LOWER(name)
+	`
+	rows, err := DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing %ss: %v", kind.Noun, err)
+	}
+	defer rows.Close()
+
+	items := []*models.Label{}
+	for rows.Next() {
+		item, err := scanLabel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning %s: %v", kind.Noun, err)
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// Rename renames one of userID's labels. It returns sql.ErrNoRows when the label
+// doesn't exist or belongs to another user.
+func (kind LabelKind) Rename(labelID string, userID string, name string) (*models.Label, error) {
+	query := `
+		UPDATE ` + kind.table + `
+		SET name = $1
+		WHERE id = $2 AND user_id = $3
+		RETURNING ` + labelColumns
+
+	item, err := scanLabel(DB.QueryRow(query, name, labelID, userID))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrDuplicateName
+		}
+		return nil, err
+	}
+	return item, nil
+}
+
+// Delete deletes one of userID's labels and detaches it from every conversation; the
+// conversations are kept. It returns sql.ErrNoRows when the label doesn't exist or
+// belongs to another user.
+func (kind LabelKind) Delete(labelID string, userID string) error {
+	result, err := DB.Exec(`DELETE FROM `+kind.table+` WHERE id = $1 AND user_id = $2`, labelID, userID)
+	if err != nil {
+		return fmt.Errorf("error deleting %s: %v", kind.Noun, err)
+	}
+	return requireAffected(result)
+}
+
+// Attach puts one of userID's labels on a conversation. Attaching a label twice is a
+// no-op. It returns sql.ErrNoRows when the label isn't userID's.
+func (kind LabelKind) Attach(conversationID string, labelID string, userID string) error {
+	query := `
+		INSERT INTO ` + kind.linkTable + ` (conversation_id, ` + kind.linkColumn + `)
+		SELECT $1, id FROM ` + kind.table + ` WHERE id = $2 AND user_id = $3
+		ON CONFLICT DO NOTHING
+	`
+	if _, err := DB.Exec(query, conversationID, labelID, userID); err != nil {
+		return fmt.Errorf("error attaching %s to conversation: %v", kind.Noun, err)
+	}
+	return requireOwned(kind.table, labelID, userID)
+}
+
+func (kind LabelKind) Detach(conversationID string, labelID string) error {
+	query := `
+		DELETE FROM ` + kind.linkTable + `
+		WHERE conversation_id = $1 AND ` + kind.linkColumn + ` = $2
+	`
+	if _, err := DB.Exec(query, conversationID, labelID); err != nil {
+		return fmt.Errorf("error detaching %s from conversation: %v", kind.Noun, err)
+	}
+	return nil
+}
+
+func requireAffected(result sql.Result) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// requireOwned returns sql.ErrNoRows unless table has a row with the given id owned
+// by userID. table is always a constant from this package.
+func requireOwned(table string, id string, userID string) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM ` + table + ` WHERE id = $1 AND user_id = $2)`
+	if err := DB.QueryRow(query, id, userID).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return nil
+}
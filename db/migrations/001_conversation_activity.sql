@@ -0,0 +1,18 @@
+-- Track recent activity on conversations and support title search.
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE conversations
+	ADD COLUMN IF NOT EXISTS last_message_at TIMESTAMPTZ;
+
+UPDATE conversations
+SET last_message_at = created_at
+WHERE last_message_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS conversations_user_activity_idx
+	ON conversations (user_id, (COALESCE(last_message_at, created_at)) DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS conversations_user_created_idx
+	ON conversations (user_id, created_at DESC, id DESC);
+
+CREATE INDEX IF NOT EXISTS conversations_title_trgm_idx
+	ON conversations USING GIN (title gin_trgm_ops);
@@ -0,0 +1,14 @@
+-- Read-only public links to a single conversation. Only a hash of the share
+-- token is stored; the token itself is returned once when the share is created.
+CREATE TABLE IF NOT EXISTS conversation_shares (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+	user_id UUID NOT NULL,
+	token_hash TEXT NOT NULL UNIQUE,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	expires_at TIMESTAMPTZ NOT NULL,
+	revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS conversation_shares_user_idx
+	ON conversation_shares (user_id, created_at DESC);
@@ -0,0 +1,8 @@
+-- Link forked conversations to the conversation and message they branched from.
+ALTER TABLE conversations
+	ADD COLUMN IF NOT EXISTS parent_conversation_id UUID REFERENCES conversations (id) ON DELETE SET NULL,
+	ADD COLUMN IF NOT EXISTS forked_from_sequence BIGINT;
+
+CREATE INDEX IF NOT EXISTS conversations_parent_idx
+	ON conversations (parent_conversation_id)
+	WHERE parent_conversation_id IS NOT NULL;
@@ -0,0 +1,10 @@
+-- Archive, pin and soft-delete conversations. Soft-deleted conversations can be
+-- restored until the purge job removes them after the retention window.
+ALTER TABLE conversations
+	ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE,
+	ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+CREATE INDEX IF NOT EXISTS conversations_deleted_at_idx
+	ON conversations (deleted_at)
+	WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,39 @@
+-- User-defined tags and folders. A conversation can carry any number of tags and
+-- sit in any number of folders; deleting a tag, folder or conversation removes its links.
+CREATE TABLE IF NOT EXISTS tags (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id UUID NOT NULL,
+	name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS tags_user_name_idx
+	ON tags (user_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS folders (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	user_id UUID NOT NULL,
+	name TEXT NOT NULL,
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS folders_user_name_idx
+	ON folders (user_id, LOWER(name));
+
+CREATE TABLE IF NOT EXISTS conversation_tags (
+	conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+	tag_id UUID NOT NULL REFERENCES tags (id) ON DELETE CASCADE,
+	PRIMARY KEY (conversation_id, tag_id)
+);
+
+CREATE INDEX IF NOT EXISTS conversation_tags_tag_idx
+	ON conversation_tags (tag_id);
+
+CREATE TABLE IF NOT EXISTS conversation_folders (
+	conversation_id UUID NOT NULL REFERENCES conversations (id) ON DELETE CASCADE,
+	folder_id UUID NOT NULL REFERENCES folders (id) ON DELETE CASCADE,
+	PRIMARY KEY (conversation_id, folder_id)
+);
+
+CREATE INDEX IF NOT EXISTS conversation_folders_folder_idx
+	ON conversation_folders (folder_id);
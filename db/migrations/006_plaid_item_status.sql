@@ -0,0 +1,34 @@
+-- Item health: lowercase statuses, the Plaid error code behind the current status and
+-- a history of every status change.
+ALTER TABLE plaid_items
+	ADD COLUMN IF NOT EXISTS error_code TEXT,
+	ADD COLUMN IF NOT EXISTS new_accounts_available BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- HEALTHY came from CreatePlaidItem and active from relinking an existing item
+UPDATE plaid_items
+SET status = CASE
+	WHEN status IS NULL OR status IN ('HEALTHY', 'active') THEN 'healthy'
+	WHEN LOWER(status) IN ('healthy', 'login_required', 'pending_expiration',
+		'pending_disconnect', 'revoked', 'error') THEN LOWER(status)
+	ELSE 'error'
+END;
+
+ALTER TABLE plaid_items
+	ALTER COLUMN status SET DEFAULT 'healthy',
+	DROP CONSTRAINT IF EXISTS plaid_items_status_check,
+	ADD CONSTRAINT plaid_items_status_check CHECK (status IN ('healthy', 'login_required',
+		'pending_expiration', 'pending_disconnect', 'revoked', 'error'));
+
+-- History rows outlive the item so unlinked banks keep their audit trail
+CREATE TABLE IF NOT EXISTS plaid_item_status_history (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	item_id TEXT NOT NULL,
+	from_status TEXT NOT NULL,
+	to_status TEXT NOT NULL,
+	error_code TEXT,
+	reason TEXT NOT NULL DEFAULT '',
+	created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS plaid_item_status_history_item_idx
+	ON plaid_item_status_history (item_id, created_at DESC);
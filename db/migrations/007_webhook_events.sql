@@ -0,0 +1,25 @@
+-- Every webhook we receive, with its raw payload and how processing went. Stripe
+-- events are keyed by their event ID; Plaid sends none, so its key is a SHA-256 of
+-- the body.
+CREATE TABLE IF NOT EXISTS webhook_events (
+	id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+	source TEXT NOT NULL,
+	event_id TEXT NOT NULL,
+	event_type TEXT NOT NULL DEFAULT '',
+	payload BYTEA NOT NULL,
+	status TEXT NOT NULL DEFAULT 'received',
+	error TEXT,
+	attempts INTEGER NOT NULL DEFAULT 0,
+	received_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+	processed_at TIMESTAMPTZ,
+	CONSTRAINT webhook_events_status_check CHECK (status IN ('received', 'processing', 'processed', 'failed'))
+);
+
+-- Not unique: an identical Plaid body outside the dedupe window is a new event
+CREATE INDEX IF NOT EXISTS webhook_events_source_event_idx
+	ON webhook_events (source, event_id, received_at DESC);
+
+CREATE INDEX IF NOT EXISTS webhook_events_status_idx
+	ON webhook_events (status, received_at DESC)
+	WHERE status <> 'processed';
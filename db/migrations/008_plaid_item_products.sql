@@ -0,0 +1,5 @@
+-- Plaid products enabled on each item. Investments and liabilities are optional in
+-- Link, and calling their endpoints adds the product to the item, so they are only
+-- fetched for items that have them. NULL until the item has been looked up.
+ALTER TABLE plaid_items
+	ADD COLUMN IF NOT EXISTS products TEXT[];
@@ -0,0 +1,4 @@
+-- Account IDs recorded when an item is being removed. Plaid forgets the item's
+-- accounts once it is removed, and cleanup still needs them if it has to be retried.
+ALTER TABLE plaid_items
+	ADD COLUMN IF NOT EXISTS account_ids TEXT[];
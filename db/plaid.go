@@ -2,15 +2,21 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"finance-chatbot/api/models"
 	"fmt"
+
+	"github.com/lib/pq"
 )
 
+// ErrInvalidTransition is returned when an item can't move to the requested status
+var ErrInvalidTransition = errors.New("invalid item status transition")
+
 // CreatePlaidItem creates a new Plaid item in the database
 func CreatePlaidItem(userID, accessToken, itemID string) (*models.PlaidItem, error) {
 	query := `
 		INSERT INTO plaid_items (user_id, access_token, item_id, status)
-		VALUES ($1, $2, $3, 'HEALTHY')
+		VALUES ($1, $2, $3, 'healthy')
 		RETURNING id, user_id, access_token, item_id, status, created_at, updated_at
 	`
 
@@ -34,7 +40,7 @@ func CreatePlaidItem(userID, accessToken, itemID string) (*models.PlaidItem, err
 // GetPlaidItemsByUserID retrieves all Plaid items for a user
 func GetPlaidItemsByUserID(userID string) ([]*models.PlaidItem, error) {
 	query := `
-		SELECT id, user_id, access_token, item_id, status, created_at, updated_at, last_synced_at, sync_status, transaction_cursor
+		SELECT id, user_id, access_token, item_id, status, error_code, new_accounts_available, products, account_ids, created_at, updated_at, last_synced_at, sync_status, transaction_cursor
 		FROM plaid_items
 		WHERE user_id = $1
 		ORDER BY created_at DESC
@@ -55,6 +61,10 @@ func GetPlaidItemsByUserID(userID string) ([]*models.PlaidItem, error) {
 			&item.AccessToken,
 			&item.ItemID,
 			&item.Status,
+			&item.ErrorCode,
+			&item.NewAccountsAvailable,
+			pq.Array(&item.Products),
+			pq.Array(&item.AccountIDs),
 			&item.CreatedAt,
 			&item.UpdatedAt,
 			&item.LastSyncedAt,
@@ -74,11 +84,60 @@ func GetPlaidItemsByUserID(userID string) ([]*models.PlaidItem, error) {
 	return items, nil
 }
 
-func DeletePlaidItemsByUserID(userId string) ([]string, error) {
+// GetAllPlaidItems retrieves every Plaid item, for jobs that process all users
+func GetAllPlaidItems() ([]*models.PlaidItem, error) {
+	query := `
+		SELECT id, user_id, access_token, item_id, status, error_code, new_accounts_available, products, account_ids, created_at, updated_at, last_synced_at, sync_status, transaction_cursor
+		FROM plaid_items
+		ORDER BY user_id, created_at
+	`
+
+	rows, err := DB.Query(query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting Plaid items: %v", err)
+	}
+	defer rows.Close()
+
+	var items []*models.PlaidItem
+	for rows.Next() {
+		item := &models.PlaidItem{}
+		err := rows.Scan(
+			&item.ID,
+			&item.UserID,
+			&item.AccessToken,
+			&item.ItemID,
+			&item.Status,
+			&item.ErrorCode,
+			&item.NewAccountsAvailable,
+			pq.Array(&item.Products),
+			pq.Array(&item.AccountIDs),
+			&item.CreatedAt,
+			&item.UpdatedAt,
+			&item.LastSyncedAt,
+			&item.SyncStatus,
+			&item.Cursor,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning Plaid item: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating Plaid items: %v", err)
+	}
+
+	return items, nil
+}
+
+// DeletePlaidItemsByUserID deletes all of a user's Plaid items and returns them so
+// they can be removed from Plaid and Qdrant. Only ItemID, AccessToken and AccountIDs
+// are set.
+func DeletePlaidItemsByUserID(userId string) ([]*models.PlaidItem, error) {
 	query := `
 		DELETE FROM plaid_items
 		WHERE user_id = $1
-		RETURNING access_token
+		RETURNING item_id, access_token, account_ids
 	`
 
 	rows, err := DB.Query(query, userId)
@@ -87,47 +146,37 @@ func DeletePlaidItemsByUserID(userId string) ([]string, error) {
 	}
 	defer rows.Close()
 
-	var accessTokens []string
+	var items []*models.PlaidItem
 	for rows.Next() {
-		var token string
-		if err := rows.Scan(&token); err != nil {
+		item := &models.PlaidItem{UserID: userId}
+		if err := rows.Scan(&item.ItemID, &item.AccessToken, pq.Array(&item.AccountIDs)); err != nil {
 			return nil, err
 		}
-		accessTokens = append(accessTokens, token)
+		items = append(items, item)
 	}
 
-	return accessTokens, nil
+	return items, rows.Err()
 }
 
-// UpdatePlaidItemStatus updates the status of a Plaid item
-func UpdatePlaidItemStatus(itemID, status string) error {
+// DeletePlaidItem deletes one of a user's Plaid items. It returns sql.ErrNoRows when
+// the item doesn't exist or belongs to another user.
+func DeletePlaidItem(itemID string, userID string) error {
 	query := `
-		UPDATE plaid_items
-		SET status = $1, updated_at = CURRENT_TIMESTAMP
-		WHERE item_id = $2
+		DELETE FROM plaid_items
+		WHERE item_id = $1 AND user_id = $2
 	`
 
-	result, err := DB.Exec(query, status, itemID)
-	if err != nil {
-		return fmt.Errorf("error updating Plaid item status: %v", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	result, err := DB.Exec(query, itemID, userID)
 	if err != nil {
-		return fmt.Errorf("error getting rows affected: %v", err)
-	}
-
-	if rowsAffected == 0 {
-		return fmt.Errorf("no Plaid item found with ID: %s", itemID)
+		return fmt.Errorf("error deleting Plaid item: %v", err)
 	}
-
-	return nil
+	return requireAffected(result)
 }
 
 // GetPlaidItemByItemID retrieves a Plaid item by its item_id
 func GetPlaidItemByItemID(itemID string) (*models.PlaidItem, error) {
 	query := `
-		SELECT id, user_id, access_token, item_id, status, created_at, updated_at, last_synced_at, sync_status, transaction_cursor
+		SELECT id, user_id, access_token, item_id, status, error_code, new_accounts_available, products, account_ids, created_at, updated_at, last_synced_at, sync_status, transaction_cursor
 		FROM plaid_items
 		WHERE item_id = $1
 	`
@@ -139,6 +188,10 @@ func GetPlaidItemByItemID(itemID string) (*models.PlaidItem, error) {
 		&item.AccessToken,
 		&item.ItemID,
 		&item.Status,
+		&item.ErrorCode,
+		&item.NewAccountsAvailable,
+		pq.Array(&item.Products),
+		pq.Array(&item.AccountIDs),
 		&item.CreatedAt,
 		&item.UpdatedAt,
 		&item.LastSyncedAt,
@@ -180,26 +233,111 @@ func UpdateSyncStatus(itemID string, syncStatus models.SyncStatus) error {
 	return nil
 }
 
-func UpdateItemStatus(itemID string, status models.ItemStatus) error {
+// TransitionItemStatus moves an item to status and records the change in the item's
+// history. errorCode is the Plaid error behind the new status, empty when there is
+// none, and reason says what triggered the change. Moving to the current status with
+// the same error code is a no-op. It returns sql.ErrNoRows when the item doesn't
+// exist and ErrInvalidTransition when the item can't move to status.
+func TransitionItemStatus(itemID string, status models.ItemStatus, errorCode string, reason string) (err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var current models.ItemStatus
+	var currentErrorCode sql.NullString
+	err = tx.QueryRow(`
+		SELECT status, error_code
+		FROM plaid_items
+		WHERE item_id = $1
+		FOR UPDATE
+	`, itemID).Scan(&current, &currentErrorCode)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return err
+		}
+		return fmt.Errorf("error getting Plaid item status: %v", err)
+	}
+
+	if current == status && currentErrorCode.String == errorCode {
+		return nil
+	}
+	if !current.CanTransitionTo(status) {
+		return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, current, status)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE plaid_items
+		SET status = $1, error_code = NULLIF($2, ''), updated_at = CURRENT_TIMESTAMP
+		WHERE item_id = $3
+	`, status, errorCode, itemID)
+	if err != nil {
+		return fmt.Errorf("error updating Plaid item status: %v", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO plaid_item_status_history (item_id, from_status, to_status, error_code, reason)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5)
+	`, itemID, current, status, errorCode, reason)
+	if err != nil {
+		return fmt.Errorf("error recording Plaid item status change: %v", err)
+	}
+
+	return nil
+}
+
+// SetNewAccountsAvailable flags whether the user can share more of the item's accounts
+func SetNewAccountsAvailable(itemID string, available bool) error {
 	query := `
 		UPDATE plaid_items
-		SET status = $1, updated_at = CURRENT_TIMESTAMP
+		SET new_accounts_available = $1, updated_at = CURRENT_TIMESTAMP
 		WHERE item_id = $2
 	`
 
-	result, err := DB.Exec(query, status, itemID)
+	result, err := DB.Exec(query, available, itemID)
 	if err != nil {
-		return fmt.Errorf("error updating Plaid item status: %v", err)
+		return fmt.Errorf("error updating Plaid item: %v", err)
 	}
+	return requireAffected(result)
+}
 
-	rowsAffected, err := result.RowsAffected()
+// SetPlaidItemProducts records the Plaid products enabled on an item
+func SetPlaidItemProducts(itemID string, products []string) error {
+	query := `
+		UPDATE plaid_items
+		SET products = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE item_id = $2
+	`
+
+	result, err := DB.Exec(query, pq.Array(products), itemID)
 	if err != nil {
-		return fmt.Errorf("error getting rows affected: %v", err)
+		return fmt.Errorf("error updating Plaid item products: %v", err)
 	}
+	return requireAffected(result)
+}
 
-	if rowsAffected == 0 {
-		return fmt.Errorf("no Plaid item found with ID: %s", itemID)
-	}
+// SetPlaidItemAccountIDs records an item's account IDs ahead of removing it
+func SetPlaidItemAccountIDs(itemID string, accountIDs []string) error {
+	query := `
+		UPDATE plaid_items
+		SET account_ids = $1, updated_at = CURRENT_TIMESTAMP
+		WHERE item_id = $2
+	`
 
-	return nil
+	result, err := DB.Exec(query, pq.Array(accountIDs), itemID)
+	if err != nil {
+		return fmt.Errorf("error updating Plaid item account IDs: %v", err)
+	}
+	return requireAffected(result)
 }
@@ -0,0 +1,107 @@
+package db
+
+import (
+	"database/sql"
+	"finance-chatbot/api/models"
+	"fmt"
+	"time"
+)
+
+const shareColumns = `id, conversation_id, user_id, created_at, expires_at, revoked_at`
+
+func scanShare(row rowScanner) (*models.ConversationShare, error) {
+	item := &models.ConversationShare{}
+	err := row.Scan(
+		&item.ID,
+		&item.ConversationID,
+		&item.UserID,
+		&item.CreatedAt,
+		&item.ExpiresAt,
+		&item.RevokedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+func CreateConversationShare(conversationID, userID, tokenHash string, expiresAt time.Time) (*models.ConversationShare, error) {
+	query := `
+		INSERT INTO conversation_shares (conversation_id, user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + shareColumns
+
+	item, err := scanShare(DB.QueryRow(query, conversationID, userID, tokenHash, expiresAt))
+	if err != nil {
+		return nil, fmt.Errorf("error creating conversation share: %v", err)
+	}
+	return item, nil
+}
+
+// GetActiveShareByTokenHash returns the unexpired, unrevoked share for a token hash,
+// or nil if there is none
+func GetActiveShareByTokenHash(tokenHash string) (*models.ConversationShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM conversation_shares
+		WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+	`
+	item, err := scanShare(DB.QueryRow(query, tokenHash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting conversation share: %v", err)
+	}
+	return item, nil
+}
+
+func ListActiveSharesByUserID(userID string) ([]*models.ConversationShare, error) {
+	query := `
+		SELECT ` + shareColumns + `
+		FROM conversation_shares
+		WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > NOW()
+		ORDER BY created_at DESC
+	`
+	rows, err := DB.Query(query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error listing conversation shares: %v", err)
+	}
+	defer rows.Close()
+
+	items := []*models.ConversationShare{}
+	for rows.Next() {
+		item, err := scanShare(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning conversation share: %v", err)
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// RevokeConversationShare revokes one of the user's shares. It returns
+// sql.ErrNoRows when the user has no active share with that ID.
+func RevokeConversationShare(shareID, userID string) error {
+	query := `
+		UPDATE conversation_shares
+		SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`
+	result, err := DB.Exec(query, shareID, userID)
+	if err != nil {
+		return fmt.Errorf("error revoking conversation share: %v", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %v", err)
+	}
+
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package db
+
+import (
+	"database/sql"
+	"finance-chatbot/api/models"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const webhookEventColumns = `id, source, event_id, event_type, payload, status, error, attempts, received_at, updated_at, processed_at`
+
+// webhookClaimTimeout is how long an event can sit in processing before another
+// delivery may take it over, for when the process handling it died
+const webhookClaimTimeout = 5 * time.Minute
+
+func scanWebhookEvent(row rowScanner) (*models.WebhookEvent, error) {
+	item := &models.WebhookEvent{}
+	err := row.Scan(
+		&item.ID,
+		&item.Source,
+		&item.EventID,
+		&item.EventType,
+		&item.Payload,
+		&item.Status,
+		&item.Error,
+		&item.Attempts,
+		&item.ReceivedAt,
+		&item.UpdatedAt,
+		&item.ProcessedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// RecordWebhookEvent stores a received webhook, or returns the earlier event with
+// the same source and event ID received within window, reporting it as a duplicate.
+// A window of 0 matches earlier events of any age. Deliveries of the same event are
+// serialized so concurrent duplicates find each other.
+func RecordWebhookEvent(source, eventID, eventType string, payload []byte, window time.Duration) (item *models.WebhookEvent, duplicate bool, err error) {
+	tx, err := DB.Begin()
+	if err != nil {
+		return nil, false, fmt.Errorf("error starting transaction: %v", err)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock(hashtext($1::text || ':' || $2::text))`, source, eventID); err != nil {
+		return nil, false, fmt.Errorf("error locking webhook event: %v", err)
+	}
+
+	query := `
+		SELECT ` + webhookEventColumns + `
+		FROM webhook_events
+		WHERE source = $1 AND event_id = $2
+			AND ($3::float8 = 0 OR received_at > NOW() - make_interval(secs => $3::float8))
+		ORDER BY received_at DESC
+		LIMIT 1
+	`
+	item, err = scanWebhookEvent(tx.QueryRow(query, source, eventID, window.Seconds()))
+	if err == nil {
+		return item, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("error getting webhook event: %v", err)
+	}
+
+	query = `
+		INSERT INTO webhook_events (source, event_id, event_type, payload)
+		VALUES ($1, $2, $3, $4)
+		RETURNING ` + webhookEventColumns
+
+	item, err = scanWebhookEvent(tx.QueryRow(query, source, eventID, eventType, payload))
+	if err != nil {
+		return nil, false, fmt.Errorf("error recording webhook event: %v", err)
+	}
+	return item, false, nil
+}
+
+// ClaimWebhookEvent marks an event as processing so only one caller applies it. New
+// and failed events can be claimed, as can events whose claim has timed out; replay
+// also allows processed events. It reports whether the claim succeeded.
+func ClaimWebhookEvent(id uuid.UUID, replay bool) (bool, error) {
+	query := `
+		UPDATE webhook_events
+		SET status = 'processing', attempts = attempts + 1, updated_at = NOW()
+		WHERE id = $1 AND (
+			status IN ('received', 'failed')
+			OR ($2::boolean AND status = 'processed')
+			OR (status = 'processing' AND updated_at < NOW() - make_interval(secs => $3::float8))
+		)
+	`
+
+	result, err := DB.Exec(query, id, replay, webhookClaimTimeout.Seconds())
+	if err != nil {
+		return false, fmt.Errorf("error claiming webhook event: %v", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error getting rows affected: %v", err)
+	}
+	return affected == 1, nil
+}
+
+// FinishWebhookEvent records the outcome of processing a claimed event
+func FinishWebhookEvent(id uuid.UUID, processErr error) error {
+	status := models.WebhookEventProcessed
+	var errorMessage *string
+	if processErr != nil {
+		status = models.WebhookEventFailed
+		message := processErr.Error()
+		errorMessage = &message
+	}
+
+	query := `
+		UPDATE webhook_events
+		SET status = $1,
+			error = $2,
+			updated_at = NOW(),
+			processed_at = CASE WHEN $1::text = 'processed' THEN NOW() ELSE processed_at END
+		WHERE id = $3
+	`
+
+	result, err := DB.Exec(query, status, errorMessage, id)
+	if err != nil {
+		return fmt.Errorf("error finishing webhook event: %v", err)
+	}
+	return requireAffected(result)
+}
+
+// GetWebhookEvent returns a stored event, or nil if there is none
+func GetWebhookEvent(id uuid.UUID) (*models.WebhookEvent, error) {
+	query := `
+		SELECT ` + webhookEventColumns + `
+		FROM webhook_events
+		WHERE id = $1
+	`
+
+	item, err := scanWebhookEvent(DB.QueryRow(query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting webhook event: %v", err)
+	}
+	return item, nil
+}
@@ -0,0 +1,179 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"fmt"
+	"html/template"
+	"strings"
+	"time"
+)
+
+const (
+	FormatMarkdown = "md"
+	FormatJSON     = "json"
+	FormatHTML     = "html"
+)
+
+// Render encodes the export in the requested format and returns the bytes with
+// their content type
+func Render(doc *models.ConversationExport, format string) ([]byte, string, error) {
+	switch format {
+	case FormatMarkdown:
+		return Markdown(doc), "text/markdown; charset=utf-8", nil
+	case FormatJSON:
+		body, err := JSON(doc)
+		return body, "application/json; charset=utf-8", err
+	case FormatHTML:
+		body, err := HTML(doc)
+		return body, "text/html; charset=utf-8", err
+	default:
+		return nil, "", fmt.Errorf("unsupported export format: %s", format)
+	}
+}
+
+func JSON(doc *models.ConversationExport) ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+func Markdown(doc *models.ConversationExport) []byte {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", doc.Conversation.Title)
+	fmt.Fprintf(&b, "_Started %s · Exported %s_\n\n",
+		formatTime(doc.Conversation.CreatedAt), formatTime(doc.ExportedAt))
+
+	if doc.Context != nil {
+		b.WriteString("## Financial snapshot\n\n")
+		if doc.Context.Income > 0 {
+			fmt.Fprintf(&b, "- Income: %s\n", formatAmount(doc.Context.Income, ""))
+		}
+		if doc.Context.SavingsGoal > 0 {
+			fmt.Fprintf(&b, "- Savings goal: %s\n", formatAmount(doc.Context.SavingsGoal, ""))
+		}
+		for _, expense := range doc.Context.AdditionalExpenses {
+			fmt.Fprintf(&b, "- %s: %s / month\n", expense.Name, formatAmount(float64(expense.Amount), ""))
+		}
+		if len(doc.Context.Accounts) > 0 {
+			b.WriteString("\n| Account | Type | Balance |\n|---|---|---|\n")
+			for _, account := range doc.Context.Accounts {
+				fmt.Fprintf(&b, "| %s | %s | %s |\n",
+					escapeTableCell(accountLabel(account)),
+					escapeTableCell(account.Subtype),
+					formatAmount(account.Balances.Current, account.Balances.IsoCurrencyCode))
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Conversation\n\n")
+	for _, message := range doc.Messages {
+		fmt.Fprintf(&b, "**%s** · %s\n\n%s\n\n", SenderLabel(message.Sender), formatTime(MessageTime(message)), message.Text)
+	}
+
+	return []byte(b.String())
+}
+
+var htmlTemplate = template.Must(template.New("conversation").Funcs(template.FuncMap{
+	"sender":      SenderLabel,
+	"isUser":      func(sender string) bool { return sender == models.SenderUser },
+	"time":        formatTime,
+	"messageTime": MessageTime,
+	"amount":      formatAmount,
+	"account":     accountLabel,
+	"float":       func(amount int) float64 { return float64(amount) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Conversation.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; color: #1f2328; max-width: 760px; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+h1 { margin-bottom: 0.25rem; }
+.meta { color: #656d76; font-size: 0.9rem; }
+table { border-collapse: collapse; width: 100%; margin: 1rem 0; }
+th, td { border: 1px solid #d0d7de; padding: 0.4rem 0.6rem; text-align: left; }
+.message { border-radius: 8px; padding: 0.75rem 1rem; margin: 0.75rem 0; page-break-inside: avoid; }
+.user { background: #ddf4ff; }
+.assistant { background: #f6f8fa; }
+.sender { font-weight: 600; }
+.text { white-space: pre-wrap; margin-top: 0.25rem; }
+@media print { body { margin: 0; max-width: none; } }
+</style>
+</head>
+<body>
+<h1>{{.Conversation.Title}}</h1>
+<p class="meta">Started {{time .Conversation.CreatedAt}} · Exported {{time .ExportedAt}}</p>
+{{with .Context}}
+<h2>Financial snapshot</h2>
+<ul>
+{{if gt .Income 0.0}}<li>Income: {{amount .Income ""}}</li>{{end}}
+{{if gt .SavingsGoal 0.0}}<li>Savings goal: {{amount .SavingsGoal ""}}</li>{{end}}
+{{range .AdditionalExpenses}}<li>{{.Name}}: {{amount (float .Amount) ""}} / month</li>{{end}}
+</ul>
+{{if .Accounts}}
+<table>
+<tr><th>Account</th><th>Type</th><th>Balance</th></tr>
+{{range .Accounts}}<tr><td>{{account .}}</td><td>{{.Subtype}}</td><td>{{amount .Balances.Current .Balances.IsoCurrencyCode}}</td></tr>
+{{end}}
+</table>
+{{end}}
+{{end}}
+<h2>Conversation</h2>
+{{range .Messages}}
+<div class="message {{if isUser .Sender}}user{{else}}assistant{{end}}">
+<div><span class="sender">{{sender .Sender}}</span> <span class="meta">{{time (messageTime .)}}</span></div>
+<div class="text">{{.Text}}</div>
+</div>
+{{end}}
+</body>
+</html>
+`))
+
+func HTML(doc *models.ConversationExport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, doc); err != nil {
+		return nil, fmt.Errorf("error rendering HTML export: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+func SenderLabel(sender string) string {
+	if sender == models.SenderUser {
+		return "You"
+	}
+	return "Assistant"
+}
+
+// MessageTime converts a message timestamp to a time. Messages stored before the
+// switch to millisecond timestamps are still in seconds.
+func MessageTime(message models.Message) time.Time {
+	if message.Timestamp < mongodb.SecondsCutoff {
+		return time.Unix(message.Timestamp, 0)
+	}
+	return time.UnixMilli(message.Timestamp)
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format("Jan 2, 2006 15:04 MST")
+}
+
+func formatAmount(amount float64, currency string) string {
+	if currency == "" || currency == "USD" {
+		return fmt.Sprintf("$%.2f", amount)
+	}
+	return fmt.Sprintf("%.2f %s", amount, currency)
+}
+
+func accountLabel(account models.Account) string {
+	if account.Mask != "" {
+		return fmt.Sprintf("%s ••%s", account.Name, account.Mask)
+	}
+	return account.Name
+}
+
+func escapeTableCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
@@ -0,0 +1,169 @@
+package followups
+
+import (
+	"context"
+	"finance-chatbot/api/llm"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"finance-chatbot/api/sse"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// transcriptLength is how many recent messages are shown to the model
+	transcriptLength = 6
+
+	// The AI service may publish the final chunk before it has saved the reply,
+	// so the stored message is looked up a few times before giving up
+	lookupAttempts = 4
+	lookupBackoff  = 500 * time.Millisecond
+
+	generateTimeout = 30 * time.Second
+)
+
+// missingGenerationIDs logs once that suggestions are not being persisted
+var missingGenerationIDs sync.Once
+
+// repliesHaveGenerationID reports whether the AI service stores the generation ID on
+// the replies it saves. Until it does, replies can't be matched to their generation,
+// so suggestions are only sent to the client and not stored.
+func repliesHaveGenerationID() bool {
+	return os.Getenv("AI_REPLIES_HAVE_GENERATION_ID") == "true"
+}
+
+type suggestionsEvent struct {
+	GenerationID string   `json:"generation_id,omitempty"`
+	Suggestions  []string `json:"suggestions"`
+}
+
+// HandleGenerationComplete generates follow-up prompts for a finished answer, stores
+// them on the assistant message and sends them to the client as a suggestions event.
+// Failures are logged; the answer itself is never affected.
+func HandleGenerationComplete(response models.AIResponse) {
+	ctx, cancel := context.WithTimeout(context.Background(), generateTimeout)
+	defer cancel()
+
+	log := logger.Get().With(
+		zap.String("conversation_id", response.ConversationID),
+		zap.String("generation_id", response.GenerationID))
+
+	conversationContext, err := mongodb.GetConversationContext(ctx, response.ConversationID)
+	if err != nil {
+		log.Error("error fetching context for follow-up suggestions", zap.Error(err))
+		return
+	}
+	if conversationContext == nil {
+		log.Warn("no context for follow-up suggestions")
+		return
+	}
+
+	// The reply is found first so the transcript includes it
+	var reply *models.Message
+	if repliesHaveGenerationID() {
+		reply, err = findReply(ctx, response.ConversationID, response.GenerationID)
+		if err != nil {
+			log.Error("error finding assistant reply for follow-up suggestions", zap.Error(err))
+		} else if reply == nil {
+			log.Warn("assistant reply not saved under its generation ID, follow-up suggestions will not persist")
+		}
+	} else {
+		missingGenerationIDs.Do(func() {
+			logger.Get().Warn("AI_REPLIES_HAVE_GENERATION_ID is not set, follow-up suggestions will not persist")
+		})
+	}
+
+	messages, _, err := mongodb.GetMessagesByConversationID(ctx, response.ConversationID, mongodb.MessageListParams{Limit: transcriptLength})
+	if err != nil {
+		log.Error("error fetching messages for follow-up suggestions", zap.Error(err))
+		return
+	}
+
+	suggestions, err := llm.SuggestFollowUps(summarizeContext(conversationContext), buildTranscript(messages))
+	if err != nil {
+		log.Error("error generating follow-up suggestions", zap.Error(err))
+		return
+	}
+
+	if reply != nil {
+		if err := mongodb.SetMessageSuggestions(ctx, reply.ID, suggestions); err != nil {
+			log.Error("error saving follow-up suggestions", zap.Error(err))
+		}
+	}
+
+	sse.SendEvent(response.ConversationID, sse.EventSuggestions, suggestionsEvent{
+		GenerationID: response.GenerationID,
+		Suggestions:  suggestions,
+	})
+}
+
+func findReply(ctx context.Context, conversationID string, generationID string) (*models.Message, error) {
+	backoff := lookupBackoff
+	for attempt := 1; ; attempt++ {
+		reply, err := mongodb.GetAssistantReply(ctx, conversationID, generationID)
+		if err != nil || reply != nil || attempt == lookupAttempts {
+			return reply, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// buildTranscript converts stored messages into chat turns, skipping failed replies
+func buildTranscript(messages []models.Message) []llm.Message {
+	transcript := make([]llm.Message, 0, len(messages))
+	for _, message := range messages {
+		if message.Error || message.Text == "" {
+			continue
+		}
+		role := "assistant"
+		if message.Sender == models.SenderUser {
+			role = "user"
+		}
+		transcript = append(transcript, llm.Message{Role: role, Content: message.Text})
+	}
+	return transcript
+}
+
+// summarizeContext describes the user's finances in a few lines for the prompt
+func summarizeContext(conversationContext *models.Context) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "Monthly income: %.2f\n", conversationContext.Income)
+	fmt.Fprintf(&b, "Savings goal: %.2f\n", conversationContext.SavingsGoal)
+
+	if len(conversationContext.AdditionalExpenses) > 0 {
+		b.WriteString("Monthly expenses:\n")
+		for _, expense := range conversationContext.AdditionalExpenses {
+			fmt.Fprintf(&b, "- %s: %d\n", expense.Name, expense.Amount)
+		}
+	}
+
+	if len(conversationContext.Accounts) > 0 {
+		b.WriteString("Accounts:\n")
+		for _, account := range conversationContext.Accounts {
+			fmt.Fprintf(&b, "- %s (%s", account.Name, account.Type)
+			if account.Subtype != "" {
+				fmt.Fprintf(&b, ", %s", account.Subtype)
+			}
+			fmt.Fprintf(&b, "): balance %.2f", account.Balances.Current)
+			if account.Balances.Limit != nil {
+				fmt.Fprintf(&b, ", limit %.2f", *account.Balances.Limit)
+			}
+			b.WriteString("\n")
+		}
+	}
+
+	return b.String()
+}
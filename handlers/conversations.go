@@ -1,12 +1,17 @@
 package handlers
 
 import (
+	"database/sql"
+	"errors"
 	"finance-chatbot/api/db"
+	"finance-chatbot/api/jobs"
 	"finance-chatbot/api/llm"
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/models"
 	"finance-chatbot/api/mongodb"
+	"io"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
@@ -16,6 +21,22 @@ type NewConversationRequest struct {
 	Message string `json:"message" bson:"message"`
 }
 
+// GetConversationsRequest filters the conversation list. Archived and deleted
+// conversations are left out unless asked for, and the deleted list holds archived
+// and unarchived conversations alike unless Archived is set; Pinned, TagID and
+// FolderID are ignored when unset.
+type GetConversationsRequest struct {
+	Cursor   string `json:"cursor"`
+	Limit    int    `json:"limit"`
+	Search   string `json:"search"`
+	Sort     string `json:"sort"`
+	Archived *bool  `json:"archived"`
+	Pinned   *bool  `json:"pinned"`
+	Deleted  bool   `json:"deleted"`
+	TagID    string `json:"tag_id"`
+	FolderID string `json:"folder_id"`
+}
+
 type UpdateConversationTitleRequest struct {
 	ConversationID string `json:"conversation_id" bson:"conversation_id"`
 	Title          string `json:"title" bson:"title"`
@@ -25,6 +46,26 @@ type DeleteConversationRequest struct {
 	ConversationID string `json:"conversation_id" bson:"conversation_id"`
 }
 
+type RestoreConversationRequest struct {
+	ConversationID string `json:"conversation_id"`
+}
+
+type ArchiveConversationRequest struct {
+	ConversationID string `json:"conversation_id"`
+	Archived       *bool  `json:"archived"`
+}
+
+type PinConversationRequest struct {
+	ConversationID string `json:"conversation_id"`
+	Pinned         *bool  `json:"pinned"`
+}
+
+type ForkConversationRequest struct {
+	ConversationID  string `json:"conversation_id" binding:"required"`
+	MessageSequence int64  `json:"message_sequence" binding:"required"`
+	Title           string `json:"title"`
+}
+
 func HandleCreateNewConversation(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -47,11 +88,20 @@ func HandleCreateNewConversation(c *gin.Context) {
 		return
 	}
 
+	// Tag suggestions are a nicety, so they're fetched alongside the title and
+	// dropped on any error rather than failing the request
+	suggestedTags := make(chan []string, 1)
+	go func() {
+		suggestedTags <- suggestTags(claims.Sub, req.Message)
+	}()
+
+	// A failed title shouldn't stop the conversation from being created
 	title, err := llm.GenerateChatTitle(req.Message)
 	if err != nil {
-		logger.Get().Error("error generating chat title", zap.Error(err))
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
+		logger.Get().Warn("error generating chat title, using the default",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		title = llm.DefaultChatTitle
 	}
 
 	conversation, err := db.CreateConversation(claims.Sub, title)
@@ -97,10 +147,29 @@ func HandleCreateNewConversation(c *gin.Context) {
 		ConversationID: conversation.ID.String(),
 		Text:           req.Message,
 	}
-	c.JSON(http.StatusOK, gin.H{"conversation_id": conversation.ID.String(), "conversation_title": conversation.Title})
-	processUserMessage(c.Request.Context(), claims.Sub, msg)
+	if err := processUserMessage(c.Request.Context(), claims.Sub, msg); err != nil {
+		logger.Get().Error("error processing first message",
+			zap.String("conversation_id", conversation.ID.String()),
+			zap.Error(err))
+		// The conversation exists, so the client can send the message to it again
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":           err.Error(),
+			"conversation_id": conversation.ID.String(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversation_id":    conversation.ID.String(),
+		"conversation_title": conversation.Title,
+		"generation_id":      msg.GenerationID,
+		"suggested_tags":     <-suggestedTags,
+	})
 }
 
+// HandleGetConversations lists the user's conversations. With a cursor or limit it
+// returns one page as {conversations, next_cursor}; without either it returns every
+// matching conversation as a bare array, as it did before pagination.
 func HandleGetConversations(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -116,8 +185,46 @@ func HandleGetConversations(c *gin.Context) {
 		return
 	}
 
-	conversations, err := db.GetAllConversationsByUserID(claims.Sub)
+	// The body is optional; an empty request returns the first page sorted by recent activity
+	var req GetConversationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params := db.ConversationListParams{
+		Cursor:   req.Cursor,
+		Limit:    req.Limit,
+		Search:   req.Search,
+		Sort:     req.Sort,
+		Archived: req.Archived,
+		Pinned:   req.Pinned,
+		Deleted:  req.Deleted,
+		TagID:    req.TagID,
+		FolderID: req.FolderID,
+	}
+
+	// Clients from before pagination send neither a cursor nor a limit and expect
+	// every conversation as a bare array
+	paginated := req.Cursor != "" || req.Limit != 0
+
+	var conversations []*models.Conversation
+	var nextCursor string
+	var err error
+	if paginated {
+		conversations, nextCursor, err = db.ListConversationsByUserID(claims.Sub, params)
+	} else {
+		conversations, err = listAllConversations(claims.Sub, params)
+	}
 	if err != nil {
+		if errors.Is(err, db.ErrInvalidListParams) {
+			logger.Get().Error("invalid conversation list parameters",
+				zap.String("user_id", claims.Sub),
+				zap.Error(err))
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		logger.Get().Error("error fetching conversations",
 			zap.String("user_id", claims.Sub),
 			zap.Error(err))
@@ -125,7 +232,33 @@ func HandleGetConversations(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, conversations)
+	if !paginated {
+		c.JSON(http.StatusOK, conversations)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"conversations": conversations,
+		"next_cursor":   nextCursor,
+	})
+}
+
+// listAllConversations pages through every conversation matching params
+func listAllConversations(userID string, params db.ConversationListParams) ([]*models.Conversation, error) {
+	params.Limit = db.MaxConversationPageSize
+
+	conversations := []*models.Conversation{}
+	for {
+		page, nextCursor, err := db.ListConversationsByUserID(userID, params)
+		if err != nil {
+			return nil, err
+		}
+		conversations = append(conversations, page...)
+		if nextCursor == "" {
+			return conversations, nil
+		}
+		params.Cursor = nextCursor
+	}
 }
 
 func HandleUpdateConversation(c *gin.Context) {
@@ -158,18 +291,8 @@ func HandleUpdateConversation(c *gin.Context) {
 		return
 	}
 
-	conversation, err := db.GetByID(req.ConversationID)
-	if err != nil {
-		logger.Get().Error("error fetching conversation", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
-		return
-	}
-
-	if conversation.UserID != claims.Sub {
-		logger.Get().Error("unauthorized conversation update attempt",
-			zap.String("user_id", claims.Sub),
-			zap.String("conversation_id", req.ConversationID))
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized to update this conversation"})
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
@@ -216,49 +339,284 @@ func HandleDeleteConversation(c *gin.Context) {
 		return
 	}
 
-	conversation, err := db.GetByID(req.ConversationID)
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := db.SoftDeleteConversation(req.ConversationID)
 	if err != nil {
-		logger.Get().Error("error fetching conversation", zap.Error(err))
-		c.JSON(http.StatusNotFound, gin.H{"error": "Conversation not found"})
+		logger.Get().Error("error deleting conversation",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	if conversation.UserID != claims.Sub {
-		logger.Get().Error("unauthorized conversation deletion attempt",
-			zap.String("user_id", claims.Sub),
-			zap.String("conversation_id", req.ConversationID))
-		c.JSON(http.StatusForbidden, gin.H{"error": "Unauthorized to delete this conversation"})
+	logger.Get().Info("conversation moved to trash",
+		zap.String("conversation_id", req.ConversationID))
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"restorable_until": conversation.DeletedAt.Add(jobs.ConversationRetention()),
+	})
+}
+
+func HandleRestoreConversation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req RestoreConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON for restore", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ConversationID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id is required"})
+		return
+	}
+
+	if status, err := authorizeDeletedConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = db.DeleteConversation(req.ConversationID)
+	conversation, err := db.RestoreConversation(req.ConversationID, time.Now().Add(-jobs.ConversationRetention()))
 	if err != nil {
-		logger.Get().Error("error deleting conversation from Postgres",
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "conversation is not in the trash or can no longer be restored"})
+			return
+		}
+		logger.Get().Error("error restoring conversation",
 			zap.String("conversation_id", req.ConversationID),
 			zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = mongodb.DeleteConversation(c.Request.Context(), req.ConversationID)
+	logger.Get().Info("conversation restored",
+		zap.String("conversation_id", req.ConversationID))
+	c.JSON(http.StatusOK, conversation)
+}
+
+func HandleArchiveConversation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req ArchiveConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON for archive", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ConversationID == "" || req.Archived == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id and archived are required"})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := db.SetConversationArchived(req.ConversationID, *req.Archived)
 	if err != nil {
-		logger.Get().Error("error deleting conversation context from MongoDB",
+		logger.Get().Error("error archiving conversation",
 			zap.String("conversation_id", req.ConversationID),
 			zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	err = mongodb.DeleteMessages(c.Request.Context(), req.ConversationID)
+	c.JSON(http.StatusOK, conversation)
+}
+
+func HandlePinConversation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req PinConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON for pin", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.ConversationID == "" || req.Pinned == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "conversation_id and pinned are required"})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := db.SetConversationPinned(req.ConversationID, *req.Pinned)
 	if err != nil {
-		logger.Get().Error("error deleting conversation messages from MongoDB",
+		logger.Get().Error("error pinning conversation",
 			zap.String("conversation_id", req.ConversationID),
 			zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	logger.Get().Info("conversation deleted successfully",
-		zap.String("conversation_id", req.ConversationID))
-	c.JSON(http.StatusOK, map[string]any{"success": true})
+	c.JSON(http.StatusOK, conversation)
+}
+
+func HandleForkConversation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req ForkConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON for fork", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, err := mongodb.GetMessageBySequence(c.Request.Context(), req.ConversationID, req.MessageSequence)
+	if err != nil {
+		logger.Get().Error("error fetching fork point message",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Int64("sequence", req.MessageSequence),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if message == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	parent, err := db.GetByID(req.ConversationID)
+	if err != nil {
+		logger.Get().Error("error fetching conversation", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	title := req.Title
+	if title == "" {
+		title = parent.Title + " (fork)"
+	}
+
+	fork, err := db.CreateForkedConversation(claims.Sub, title, req.ConversationID, req.MessageSequence)
+	if err != nil {
+		logger.Get().Error("error creating forked conversation",
+			zap.String("user_id", claims.Sub),
+			zap.String("parent_conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := copyConversationForFork(c, claims.Sub, req.ConversationID, fork.ID.String(), message); err != nil {
+		logger.Get().Error("error copying conversation into fork",
+			zap.String("parent_conversation_id", req.ConversationID),
+			zap.String("conversation_id", fork.ID.String()),
+			zap.Error(err))
+
+		if cleanupErr := db.DeleteConversation(fork.ID.String()); cleanupErr != nil {
+			logger.Get().Error("error deleting conversation from DB",
+				zap.String("conversation_id", fork.ID.String()),
+				zap.Error(cleanupErr))
+		}
+		if cleanupErr := mongodb.DeleteConversation(c.Request.Context(), fork.ID.String()); cleanupErr != nil {
+			logger.Get().Error("error deleting conversation context from MongoDB",
+				zap.String("conversation_id", fork.ID.String()),
+				zap.Error(cleanupErr))
+		}
+		if cleanupErr := mongodb.DeleteMessages(c.Request.Context(), fork.ID.String()); cleanupErr != nil {
+			logger.Get().Error("error deleting conversation messages from MongoDB",
+				zap.String("conversation_id", fork.ID.String()),
+				zap.Error(cleanupErr))
+		}
+
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("conversation forked successfully",
+		zap.String("user_id", claims.Sub),
+		zap.String("parent_conversation_id", req.ConversationID),
+		zap.String("conversation_id", fork.ID.String()),
+		zap.Int64("sequence", req.MessageSequence))
+	c.JSON(http.StatusOK, fork)
+}
+
+// copyConversationForFork copies the parent's context snapshot and its messages up
+// to the fork point. A parent without a stored context gets a fresh one.
+func copyConversationForFork(c *gin.Context, userID string, parentID string, forkID string, forkPoint *models.Message) error {
+	conversationContext, err := mongodb.GetConversationContext(c.Request.Context(), parentID)
+	if err != nil {
+		return err
+	}
+
+	if conversationContext == nil {
+		conversationContext, err = createConversationContext(c, userID, forkID)
+		if err != nil {
+			return err
+		}
+	}
+	conversationContext.ConversationID = forkID
+	conversationContext.CreatedAt = time.Now().Unix()
+
+	if err := mongodb.CreateConversationContext(c.Request.Context(), conversationContext); err != nil {
+		return err
+	}
+
+	_, err = mongodb.CopyMessages(c.Request.Context(), parentID, forkID, forkPoint)
+	return err
 }
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/export"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+type ExportConversationRequest struct {
+	ConversationID string `json:"conversation_id" binding:"required"`
+}
+
+var filenameUnsafe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func HandleExportConversation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	format := c.DefaultQuery("format", export.FormatMarkdown)
+	if format != export.FormatMarkdown && format != export.FormatJSON && format != export.FormatHTML {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of md, json or html"})
+		return
+	}
+
+	var req ExportConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversation, err := db.GetByID(req.ConversationID)
+	if err != nil {
+		logger.Get().Error("error fetching conversation",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationContext, err := mongodb.GetConversationContext(c.Request.Context(), req.ConversationID)
+	if err != nil {
+		logger.Get().Error("error fetching conversation context",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	messages, _, err := mongodb.GetMessagesByConversationID(c.Request.Context(), req.ConversationID, mongodb.MessageListParams{})
+	if err != nil {
+		logger.Get().Error("error fetching messages",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	doc := &models.ConversationExport{
+		Version:      models.ConversationExportVersion,
+		ExportedAt:   time.Now().UTC(),
+		Conversation: *conversation,
+		Context:      conversationContext,
+		Messages:     messages,
+	}
+
+	body, contentType, err := export.Render(doc, format)
+	if err != nil {
+		logger.Get().Error("error rendering conversation export",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String("format", format),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("conversation exported",
+		zap.String("user_id", claims.Sub),
+		zap.String("conversation_id", req.ConversationID),
+		zap.String("format", format))
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, exportFilename(conversation.Title), format))
+	c.Data(http.StatusOK, contentType, body)
+}
+
+func exportFilename(title string) string {
+	name := strings.Trim(filenameUnsafe.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if name == "" {
+		return "conversation"
+	}
+	return name
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.uber.org/zap"
+)
+
+const (
+	maxFeedbackCommentLength = 2000
+	defaultFeedbackStatsDays = 30
+)
+
+type MessageFeedbackRequest struct {
+	ConversationID string                `json:"conversation_id" binding:"required"`
+	MessageID      string                `json:"message_id" binding:"required"`
+	Rating         models.FeedbackRating `json:"rating" binding:"required"`
+	Reasons        []string              `json:"reasons"`
+	Comment        string                `json:"comment"`
+}
+
+type FeedbackStatsRequest struct {
+	From *time.Time `json:"from"`
+	To   *time.Time `json:"to"`
+}
+
+func HandleMessageFeedback(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req MessageFeedbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Rating != models.FeedbackRatingUp && req.Rating != models.FeedbackRatingDown {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "rating must be up or down"})
+		return
+	}
+	for _, reason := range req.Reasons {
+		if !models.FeedbackReasons[reason] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown reason: " + reason})
+			return
+		}
+	}
+	if len(req.Comment) > maxFeedbackCommentLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "comment is too long"})
+		return
+	}
+
+	messageID, err := bson.ObjectIDFromHex(req.MessageID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	message, err := mongodb.GetMessageByID(c.Request.Context(), req.ConversationID, messageID)
+	if err != nil {
+		logger.Get().Error("error fetching message for feedback",
+			zap.String("message_id", req.MessageID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if message == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if message.Sender == models.SenderUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Feedback can only be left on assistant messages"})
+		return
+	}
+
+	reasons := req.Reasons
+	if reasons == nil {
+		reasons = []string{}
+	}
+
+	now := time.Now().UnixMilli()
+	feedback := &models.MessageFeedback{
+		MessageID:      messageID,
+		ConversationID: req.ConversationID,
+		UserID:         claims.Sub,
+		Rating:         req.Rating,
+		Reasons:        reasons,
+		Comment:        req.Comment,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if message.Metadata != nil {
+		feedback.ModelVersion = message.Metadata.ModelVersion
+	}
+
+	if err := mongodb.UpsertMessageFeedback(c.Request.Context(), feedback); err != nil {
+		logger.Get().Error("error saving feedback",
+			zap.String("message_id", req.MessageID),
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("message feedback saved",
+		zap.String("message_id", req.MessageID),
+		zap.String("user_id", claims.Sub),
+		zap.String("rating", string(req.Rating)))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleFeedbackStats reports feedback rates per day and model version. Defaults to
+// the last 30 days.
+func HandleFeedbackStats(c *gin.Context) {
+	var req FeedbackStatsRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	to := time.Now()
+	if req.To != nil {
+		to = *req.To
+	}
+	from := to.AddDate(0, 0, -defaultFeedbackStatsDays)
+	if req.From != nil {
+		from = *req.From
+	}
+	if !from.Before(to) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	stats, err := mongodb.AggregateFeedback(c.Request.Context(), from, to)
+	if err != nil {
+		logger.Get().Error("error aggregating feedback", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stats": stats})
+}
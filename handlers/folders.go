@@ -0,0 +1,21 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gin-gonic/gin"
+)
+
+var ErrFolderNotFound = errors.New("folder not found")
+
+func HandleCreateFolder(c *gin.Context) { folderHandlers.create(c) }
+
+func HandleListFolders(c *gin.Context) { folderHandlers.list(c) }
+
+func HandleUpdateFolder(c *gin.Context) { folderHandlers.update(c) }
+
+func HandleDeleteFolder(c *gin.Context) { folderHandlers.delete(c) }
+
+func HandleAddConversationFolder(c *gin.Context) { folderHandlers.attach(c) }
+
+func HandleRemoveConversationFolder(c *gin.Context) { folderHandlers.detach(c) }
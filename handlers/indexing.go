@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/qdrant"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// TransactionIndexer writes transaction embeddings to Qdrant. It is set in main once
+// the Qdrant client is initialized.
+var TransactionIndexer *qdrant.Indexer
+
+// migrationRunning guards against starting a second collection migration while one
+// is still populating
+var migrationRunning atomic.Bool
+
+// transactionHistoryDays is how much history is fetched from Plaid when reindexing
+const transactionHistoryDays = 730
+
+type ReindexTransactionsRequest struct {
+	UserID string `json:"user_id"`
+}
+
+type itemReindexResult struct {
+	ItemID  string `json:"item_id"`
+	Indexed int    `json:"indexed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HandleReindexTransactions rebuilds a user's transaction vectors from Plaid, one
+// item at a time. Items that fail are reported and the rest are still indexed.
+func HandleReindexTransactions(c *gin.Context) {
+	var req ReindexTransactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.UserID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id is required"})
+		return
+	}
+
+	items, err := db.GetPlaidItemsByUserID(req.UserID)
+	if err != nil {
+		logger.Get().Error("error fetching plaid items",
+			zap.String("user_id", req.UserID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	since := time.Now().AddDate(0, 0, -transactionHistoryDays)
+	results := make([]itemReindexResult, 0, len(items))
+	for _, item := range items {
+		result := itemReindexResult{ItemID: item.ItemID}
+
+		transactions, err := getTransactionsSince(c.Request.Context(), []*models.PlaidItem{item}, since)
+		if err == nil {
+			result.Indexed, err = TransactionIndexer.IndexItem(c.Request.Context(), req.UserID, item.ItemID, transactions)
+		}
+		if err != nil {
+			logger.Get().Error("error reindexing plaid item",
+				zap.String("user_id", req.UserID),
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+			result.Error = err.Error()
+		}
+
+		results = append(results, result)
+	}
+
+	logger.Get().Info("transactions reindexed",
+		zap.String("user_id", req.UserID),
+		zap.Int("items", len(items)))
+	c.JSON(http.StatusOK, gin.H{"items": results})
+}
+
+// HandleMigrateTransactionsCollection re-embeds every user's transactions into a new
+// collection version and swaps the alias once it is full. Migration runs in the
+// background and can take a while; progress and the outcome are logged.
+func HandleMigrateTransactionsCollection(c *gin.Context) {
+	if !migrationRunning.CompareAndSwap(false, true) {
+		c.JSON(http.StatusConflict, gin.H{"error": "A migration is already running"})
+		return
+	}
+
+	go func() {
+		defer migrationRunning.Store(false)
+
+		ctx := context.Background()
+		collection, err := qdrant.MigrateTransactionsCollection(ctx, TransactionIndexer.Dimension(), populateTransactionsCollection)
+		if err != nil {
+			logger.Get().Error("transactions collection migration failed", zap.Error(err))
+			return
+		}
+		logger.Get().Info("transactions collection migration finished",
+			zap.String("collection", collection))
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{"status": "started"})
+}
+
+// populateTransactionsCollection indexes every Plaid item into collection. Items that
+// can't be fetched from Plaid are skipped so one broken login doesn't block the
+// migration; they can be reindexed individually afterward.
+func populateTransactionsCollection(ctx context.Context, collection string) error {
+	items, err := db.GetAllPlaidItems()
+	if err != nil {
+		return err
+	}
+
+	indexer := TransactionIndexer.InCollection(collection)
+	since := time.Now().AddDate(0, 0, -transactionHistoryDays)
+
+	var indexed, skipped int
+	for _, item := range items {
+		transactions, err := getTransactionsSince(ctx, []*models.PlaidItem{item}, since)
+		if err != nil {
+			logger.Get().Warn("skipping plaid item during migration",
+				zap.String("user_id", item.UserID),
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+			skipped++
+			continue
+		}
+
+		if err := indexer.Upsert(ctx, item.UserID, item.ItemID, transactions); err != nil {
+			return err
+		}
+
+		// An item removed while it was being fetched has already been deleted from
+		// the collection, so its points would otherwise come back
+		current, err := db.GetPlaidItemByItemID(item.ItemID)
+		if err != nil {
+			return err
+		}
+		if current == nil {
+			if err := indexer.RemoveItem(ctx, item.ItemID); err != nil {
+				return err
+			}
+			skipped++
+			continue
+		}
+		indexed += len(transactions)
+	}
+
+	logger.Get().Info("populated transactions collection",
+		zap.String("collection", collection),
+		zap.Int("items", len(items)),
+		zap.Int("skipped_items", skipped),
+		zap.Int("transactions", indexed))
+	return nil
+}
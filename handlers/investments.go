@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"context"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"finance-chatbot/api/portfolio"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plaid/plaid-go/v37/plaid"
+	"go.uber.org/zap"
+)
+
+// holdingsMaxAge is how old a stored snapshot can get before the endpoints fetch it
+// again. HOLDINGS webhooks normally refresh snapshots well before that.
+const holdingsMaxAge = 24 * time.Hour
+
+var holdingSnapshots = itemSnapshots[*models.InvestmentSnapshot]{
+	name:      "holdings",
+	product:   plaid.PRODUCTS_INVESTMENTS,
+	maxAge:    holdingsMaxAge,
+	stored:    mongodb.GetInvestmentSnapshots,
+	sync:      syncItemHoldings,
+	itemID:    func(snapshot *models.InvestmentSnapshot) string { return snapshot.ItemID },
+	updatedAt: func(snapshot *models.InvestmentSnapshot) int64 { return snapshot.UpdatedAt },
+}
+
+func HandleGetHoldings(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	snapshots, err := holdingSnapshots.load(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Error("error getting holdings",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	positions := portfolio.Positions(snapshots)
+	var totalValue float64
+	for _, position := range positions {
+		totalValue += position.Value
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"holdings":    positions,
+		"total_value": totalValue,
+	})
+}
+
+func HandleGetAllocation(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	snapshots, err := holdingSnapshots.load(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Error("error getting holdings",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"allocation": portfolio.Allocate(portfolio.Positions(snapshots))})
+}
+
+// syncItemHoldings fetches an item's holdings and securities from Plaid and stores
+// them. Items without investments are stored with an empty snapshot.
+func syncItemHoldings(ctx context.Context, item *models.PlaidItem) (*models.InvestmentSnapshot, error) {
+	snapshot := &models.InvestmentSnapshot{
+		UserID:     item.UserID,
+		ItemID:     item.ItemID,
+		Holdings:   []models.Holding{},
+		Securities: []models.Security{},
+		UpdatedAt:  time.Now().Unix(),
+	}
+
+	req := plaid.NewInvestmentsHoldingsGetRequest(item.AccessToken)
+	resp, _, err := PlaidClient.PlaidApi.InvestmentsHoldingsGet(ctx).InvestmentsHoldingsGetRequest(*req).Execute()
+	if err != nil {
+		code := plaidErrorCode(err)
+		if !productUnavailableErrorCodes[code] {
+			return nil, fmt.Errorf("failed to get holdings for item %s: %w", item.ItemID, err)
+		}
+		logger.Get().Debug("item has no investments",
+			zap.String("item_id", item.ItemID),
+			zap.String("error_code", code))
+	} else {
+		for _, h := range resp.GetHoldings() {
+			snapshot.Holdings = append(snapshot.Holdings, models.Holding{
+				AccountID:        h.GetAccountId(),
+				SecurityID:       h.GetSecurityId(),
+				Quantity:         h.GetQuantity(),
+				InstitutionPrice: h.GetInstitutionPrice(),
+				InstitutionValue: h.GetInstitutionValue(),
+				CostBasis:        h.CostBasis.Get(),
+				IsoCurrencyCode:  h.GetIsoCurrencyCode(),
+			})
+		}
+
+		for _, s := range resp.GetSecurities() {
+			snapshot.Securities = append(snapshot.Securities, models.Security{
+				SecurityID:       s.GetSecurityId(),
+				Name:             s.GetName(),
+				TickerSymbol:     s.GetTickerSymbol(),
+				Type:             s.GetType(),
+				Sector:           s.GetSector(),
+				IsCashEquivalent: s.GetIsCashEquivalent(),
+				ClosePrice:       s.ClosePrice.Get(),
+				IsoCurrencyCode:  s.GetIsoCurrencyCode(),
+			})
+		}
+	}
+
+	if err := mongodb.ReplaceInvestmentSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	logger.Get().Info("synced item holdings",
+		zap.String("item_id", item.ItemID),
+		zap.Int("holding_count", len(snapshot.Holdings)))
+	return snapshot, nil
+}
@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const maxLabelNameLength = 50
+
+var ErrInvalidName = errors.New("name must be between 1 and 50 characters")
+
+// LabelRequest is the body of every tag and folder request. Tag requests name the
+// label with tag_id and folder requests with folder_id.
+type LabelRequest struct {
+	ConversationID string `json:"conversation_id"`
+	TagID          string `json:"tag_id"`
+	FolderID       string `json:"folder_id"`
+	Name           string `json:"name"`
+}
+
+// labelHandlers serves the endpoints of one kind of label. Tags and folders share
+// them and differ only in their storage, JSON field names and not-found error.
+type labelHandlers struct {
+	kind db.LabelKind
+	// idField is the request field naming the label, such as "tag_id"
+	idField string
+	// listKey wraps the list response, such as "tags"
+	listKey  string
+	notFound error
+	labelID  func(req *LabelRequest) string
+}
+
+var (
+	tagHandlers = labelHandlers{
+		kind:     db.Tags,
+		idField:  "tag_id",
+		listKey:  "tags",
+		notFound: ErrTagNotFound,
+		labelID:  func(req *LabelRequest) string { return req.TagID },
+	}
+	folderHandlers = labelHandlers{
+		kind:     db.Folders,
+		idField:  "folder_id",
+		listKey:  "folders",
+		notFound: ErrFolderNotFound,
+		labelID:  func(req *LabelRequest) string { return req.FolderID },
+	}
+)
+
+// normalizeLabelName trims a tag or folder name and checks its length
+func normalizeLabelName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxLabelNameLength {
+		return "", ErrInvalidName
+	}
+	return name, nil
+}
+
+func (h labelHandlers) create(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name, err := normalizeLabelName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := h.kind.Create(claims.Sub, name)
+	if err != nil {
+		if errors.Is(err, db.ErrDuplicateName) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Get().Error("error creating "+h.kind.Noun,
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+func (h labelHandlers) list(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	labels, err := h.kind.ListByUserID(claims.Sub)
+	if err != nil {
+		logger.Get().Error("error listing "+h.listKey,
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{h.listKey: labels})
+}
+
+func (h labelHandlers) update(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	labelID := h.labelID(&req)
+	if _, err := uuid.Parse(labelID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+		return
+	}
+
+	name, err := normalizeLabelName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	label, err := h.kind.Rename(labelID, claims.Sub, name)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+			return
+		}
+		if errors.Is(err, db.ErrDuplicateName) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Get().Error("error renaming "+h.kind.Noun,
+			zap.String(h.idField, labelID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, label)
+}
+
+func (h labelHandlers) delete(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	labelID := h.labelID(&req)
+	if _, err := uuid.Parse(labelID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+		return
+	}
+
+	if err := h.kind.Delete(labelID, claims.Sub); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+			return
+		}
+		logger.Get().Error("error deleting "+h.kind.Noun,
+			zap.String(h.idField, labelID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h labelHandlers) attach(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	labelID := h.labelID(&req)
+	if _, err := uuid.Parse(labelID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+		return
+	}
+
+	if err := h.kind.Attach(req.ConversationID, labelID, claims.Sub); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+			return
+		}
+		logger.Get().Error("error attaching "+h.kind.Noun+" to conversation",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String(h.idField, labelID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func (h labelHandlers) detach(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req LabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	labelID := h.labelID(&req)
+	if _, err := uuid.Parse(labelID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": h.notFound.Error()})
+		return
+	}
+
+	if err := h.kind.Detach(req.ConversationID, labelID); err != nil {
+		logger.Get().Error("error detaching "+h.kind.Noun+" from conversation",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String(h.idField, labelID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"context"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/plaid/plaid-go/v37/plaid"
+	"go.uber.org/zap"
+)
+
+// liabilitiesMaxAge is how old a stored snapshot can get before the endpoint fetches
+// it again. LIABILITIES webhooks normally refresh snapshots well before that.
+const liabilitiesMaxAge = 24 * time.Hour
+
+var liabilitySnapshots = itemSnapshots[*models.LiabilitiesSnapshot]{
+	name:      "liabilities",
+	product:   plaid.PRODUCTS_LIABILITIES,
+	maxAge:    liabilitiesMaxAge,
+	stored:    mongodb.GetLiabilitiesSnapshots,
+	sync:      syncItemLiabilities,
+	itemID:    func(snapshot *models.LiabilitiesSnapshot) string { return snapshot.ItemID },
+	updatedAt: func(snapshot *models.LiabilitiesSnapshot) int64 { return snapshot.UpdatedAt },
+}
+
+// purchaseAPRType is the credit card APR that applies to ordinary balances
+const purchaseAPRType = "purchase_apr"
+
+func HandleGetLiabilities(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	snapshots, err := liabilitySnapshots.load(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Error("error getting liabilities",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	debts := flattenDebts(snapshots)
+	var totalBalance float64
+	for _, debt := range debts {
+		totalBalance += debt.Balance
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"debts":         debts,
+		"total_balance": totalBalance,
+	})
+}
+
+// syncItemLiabilities fetches an item's credit cards, student loans and mortgages from
+// Plaid and stores them. Items without liabilities are stored with an empty snapshot.
+func syncItemLiabilities(ctx context.Context, item *models.PlaidItem) (*models.LiabilitiesSnapshot, error) {
+	snapshot := &models.LiabilitiesSnapshot{
+		UserID:    item.UserID,
+		ItemID:    item.ItemID,
+		Debts:     []models.Debt{},
+		UpdatedAt: time.Now().Unix(),
+	}
+
+	req := plaid.NewLiabilitiesGetRequest(item.AccessToken)
+	resp, _, err := PlaidClient.PlaidApi.LiabilitiesGet(ctx).LiabilitiesGetRequest(*req).Execute()
+	if err != nil {
+		code := plaidErrorCode(err)
+		if !productUnavailableErrorCodes[code] {
+			return nil, fmt.Errorf("failed to get liabilities for item %s: %w", item.ItemID, err)
+		}
+		logger.Get().Debug("item has no liabilities",
+			zap.String("item_id", item.ItemID),
+			zap.String("error_code", code))
+	} else {
+		accounts := make(map[string]plaid.AccountBase, len(resp.GetAccounts()))
+		for _, account := range resp.GetAccounts() {
+			accounts[account.GetAccountId()] = account
+		}
+
+		// newDebt fills in what the account itself reports
+		newDebt := func(accountID string, debtType string) models.Debt {
+			account := accounts[accountID]
+			balances := account.GetBalances()
+			return models.Debt{
+				AccountID:       accountID,
+				ItemID:          item.ItemID,
+				Name:            account.GetName(),
+				Type:            debtType,
+				Balance:         balances.GetCurrent(),
+				IsoCurrencyCode: balances.GetIsoCurrencyCode(),
+			}
+		}
+
+		liabilities := resp.GetLiabilities()
+		for _, card := range liabilities.GetCredit() {
+			debt := newDebt(card.GetAccountId(), models.DebtTypeCreditCard)
+			debt.APR = creditCardAPR(card.GetAprs())
+			debt.MinimumPayment = card.MinimumPaymentAmount.Get()
+			debt.NextPaymentDueDate = card.GetNextPaymentDueDate()
+			debt.StatementBalance = card.LastStatementBalance.Get()
+			debt.IsOverdue = card.GetIsOverdue()
+			snapshot.Debts = append(snapshot.Debts, debt)
+		}
+
+		for _, loan := range liabilities.GetStudent() {
+			debt := newDebt(loan.GetAccountId(), models.DebtTypeStudentLoan)
+			if name := loan.GetLoanName(); name != "" {
+				debt.Name = name
+			}
+			rate := loan.GetInterestRatePercentage()
+			debt.APR = &rate
+			debt.MinimumPayment = loan.MinimumPaymentAmount.Get()
+			debt.NextPaymentDueDate = loan.GetNextPaymentDueDate()
+			debt.StatementBalance = loan.LastStatementBalance.Get()
+			debt.IsOverdue = loan.GetIsOverdue()
+			snapshot.Debts = append(snapshot.Debts, debt)
+		}
+
+		for _, mortgage := range liabilities.GetMortgage() {
+			debt := newDebt(mortgage.GetAccountId(), models.DebtTypeMortgage)
+			interestRate := mortgage.GetInterestRate()
+			debt.APR = interestRate.Percentage.Get()
+			debt.MinimumPayment = mortgage.NextMonthlyPayment.Get()
+			debt.NextPaymentDueDate = mortgage.GetNextPaymentDueDate()
+			if pastDue := mortgage.GetPastDueAmount(); pastDue > 0 {
+				debt.IsOverdue = true
+			}
+			snapshot.Debts = append(snapshot.Debts, debt)
+		}
+	}
+
+	if err := mongodb.ReplaceLiabilitiesSnapshot(ctx, snapshot); err != nil {
+		return nil, err
+	}
+
+	logger.Get().Info("synced item liabilities",
+		zap.String("item_id", item.ItemID),
+		zap.Int("debt_count", len(snapshot.Debts)))
+	return snapshot, nil
+}
+
+// creditCardAPR picks the purchase APR, or the highest APR when the card reports no
+// purchase APR
+func creditCardAPR(aprs []plaid.APR) *float64 {
+	var apr *float64
+	for _, a := range aprs {
+		percentage := a.GetAprPercentage()
+		if a.GetAprType() == purchaseAPRType {
+			return &percentage
+		}
+		if apr == nil || percentage > *apr {
+			apr = &percentage
+		}
+	}
+	return apr
+}
+
+func flattenDebts(snapshots []*models.LiabilitiesSnapshot) []models.Debt {
+	debts := []models.Debt{}
+	for _, snapshot := range snapshots {
+		debts = append(debts, snapshot.Debts...)
+	}
+	return debts
+}
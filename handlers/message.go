@@ -1,17 +1,43 @@
 package handlers
 
 import (
+	"encoding/json"
+	"finance-chatbot/api/kafka"
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/models"
 	"finance-chatbot/api/mongodb"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.uber.org/zap"
 )
 
+const (
+	defaultMessagePageSize = 50
+	maxMessagePageSize     = 200
+)
+
+type EditMessageRequest struct {
+	ConversationID string `json:"conversation_id" binding:"required"`
+	Sequence       int64  `json:"sequence" binding:"required"`
+	Message        string `json:"message" binding:"required"`
+}
+
+type RegenerateMessageRequest struct {
+	ConversationID string `json:"conversation_id" binding:"required"`
+}
+
+type CancelGenerationRequest struct {
+	ConversationID string `json:"conversation_id" binding:"required"`
+	GenerationID   string `json:"generation_id" binding:"required"`
+}
+
 type GetMessagesByConversationIDRequest struct {
 	ConversationID string `json:"conversation_id" binding:"required"`
+	Before         int64  `json:"before"`
+	After          int64  `json:"after"`
+	Limit          int64  `json:"limit"`
 }
 
 func HandleSendMessage(c *gin.Context) {
@@ -35,6 +61,13 @@ func HandleSendMessage(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	// Regeneration is only requested through the edit and regenerate endpoints
+	req.Regenerate = false
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
 
 	logger.Get().Debug("received message request",
 		zap.String("conversation_id", req.ConversationID),
@@ -54,9 +87,12 @@ func HandleSendMessage(c *gin.Context) {
 		zap.String("conversation_id", req.ConversationID),
 		zap.String("user_id", claims.Sub))
 
-	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully"})
+	c.JSON(http.StatusOK, gin.H{"message": "Message sent successfully", "generation_id": req.GenerationID})
 }
 
+// HandleGetMessagesByConversationID lists a conversation's messages. With a cursor or
+// limit it returns one page as {messages, has_more}; without any it returns every
+// message as a bare array, as it did before pagination.
 func HandleGetMessagesByConversationID(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -79,7 +115,36 @@ func HandleGetMessagesByConversationID(c *gin.Context) {
 		return
 	}
 
-	messages, err := mongodb.GetMessagesByConversationID(c.Request.Context(), claims.Sub, req.ConversationID)
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.Before > 0 && req.After > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "before and after cannot be combined"})
+		return
+	}
+
+	// Clients from before pagination send no cursor or limit and expect every message
+	// as a bare array
+	paginated := req.Before > 0 || req.After > 0 || req.Limit != 0
+
+	limit := int64(0)
+	if paginated {
+		limit = req.Limit
+		if limit <= 0 {
+			limit = defaultMessagePageSize
+		}
+		if limit > maxMessagePageSize {
+			limit = maxMessagePageSize
+		}
+	}
+
+	messages, hasMore, err := mongodb.GetMessagesByConversationID(c.Request.Context(), req.ConversationID, mongodb.MessageListParams{
+		Before: req.Before,
+		After:  req.After,
+		Limit:  limit,
+	})
 	if err != nil {
 		logger.Get().Error("error fetching messages",
 			zap.String("conversation_id", req.ConversationID),
@@ -89,18 +154,225 @@ func HandleGetMessagesByConversationID(c *gin.Context) {
 		return
 	}
 
-	if len(messages) == 0 {
-		logger.Get().Debug("no messages found",
+	logger.Get().Info("messages retrieved successfully",
+		zap.String("conversation_id", req.ConversationID),
+		zap.String("user_id", claims.Sub),
+		zap.Int("message_count", len(messages)))
+
+	if !paginated {
+		c.JSON(http.StatusOK, messages)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"messages": messages,
+		"has_more": hasMore,
+	})
+}
+
+// HandleEditMessage replaces a user message with new text. The original message and
+// everything after it are superseded, and the edited message is answered afresh.
+func HandleEditMessage(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	original, err := mongodb.GetMessageBySequence(c.Request.Context(), req.ConversationID, req.Sequence)
+	if err != nil {
+		logger.Get().Error("error fetching message to edit",
 			zap.String("conversation_id", req.ConversationID),
-			zap.String("user_id", claims.Sub))
-		c.JSON(http.StatusOK, []models.Message{})
+			zap.Int64("sequence", req.Sequence),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if original == nil || original.Superseded {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Message not found"})
+		return
+	}
+	if original.Sender != models.SenderUser {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only user messages can be edited"})
 		return
 	}
 
-	logger.Get().Info("messages retrieved successfully",
+	superseded, err := mongodb.SupersedeMessagesFrom(c.Request.Context(), req.ConversationID, req.Sequence, original.Timestamp)
+	if err != nil {
+		logger.Get().Error("error superseding messages",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	msg := &models.Message{
+		ConversationID: req.ConversationID,
+		Text:           req.Message,
+		Regenerate:     true,
+	}
+	if err := processUserMessage(c.Request.Context(), claims.Sub, msg); err != nil {
+		logger.Get().Error("error processing edited message",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("message edited",
 		zap.String("conversation_id", req.ConversationID),
 		zap.String("user_id", claims.Sub),
-		zap.Int("message_count", len(messages)))
+		zap.Int64("superseded_count", superseded))
+	c.JSON(http.StatusOK, msg)
+}
+
+// HandleRegenerateMessage supersedes the replies to the latest user message and asks
+// the AI service to answer it again
+func HandleRegenerateMessage(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req RegenerateMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	c.JSON(http.StatusOK, messages)
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	latest, err := mongodb.GetLatestUserMessage(c.Request.Context(), req.ConversationID)
+	if err != nil {
+		logger.Get().Error("error fetching latest user message",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if latest == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No message to regenerate"})
+		return
+	}
+
+	superseded, err := mongodb.SupersedeMessagesFrom(c.Request.Context(), req.ConversationID, latest.Sequence+1, latest.Timestamp)
+	if err != nil {
+		logger.Get().Error("error superseding messages",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	latest.Regenerate = true
+	if err := produceUserMessage(c.Request.Context(), claims.Sub, latest); err != nil {
+		logger.Get().Error("error producing regenerate request",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("message regeneration requested",
+		zap.String("conversation_id", req.ConversationID),
+		zap.String("user_id", claims.Sub),
+		zap.Int64("superseded_count", superseded))
+	c.JSON(http.StatusOK, gin.H{"message": "Regeneration requested", "generation_id": latest.GenerationID})
+}
+
+// HandleCancelGeneration stops an answer that is still streaming. The cancel is
+// published so the AI service stops generating and every API instance stops
+// forwarding chunks, and is applied locally straight away.
+func HandleCancelGeneration(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req CancelGenerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	cancel := models.CancelGeneration{
+		ConversationID: req.ConversationID,
+		GenerationID:   req.GenerationID,
+		UserID:         claims.Sub,
+		CancelledAt:    time.Now().UnixMilli(),
+	}
+
+	messageBytes, err := json.Marshal(cancel)
+	if err != nil {
+		logger.Get().Error("failed to marshal cancel message", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := kafka.ProduceKeyedMessage(kafka.CancelTopic, req.ConversationID, messageBytes); err != nil {
+		logger.Get().Error("failed to produce cancel message",
+			zap.String("conversation_id", req.ConversationID),
+			zap.String("generation_id", req.GenerationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	kafka.WorkerPool.Cancel(req.ConversationID, req.GenerationID)
+
+	logger.Get().Info("generation cancelled",
+		zap.String("conversation_id", req.ConversationID),
+		zap.String("generation_id", req.GenerationID),
+		zap.String("user_id", claims.Sub))
+	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// conversationOwnerTTL is how long an owner stays cached after it was looked up
+const conversationOwnerTTL = 10 * time.Minute
+
+var (
+	ErrConversationNotFound  = errors.New("conversation not found")
+	ErrConversationForbidden = errors.New("conversation belongs to another user")
+)
+
+type conversationOwner struct {
+	userID  string
+	deleted bool
+}
+
+type cachedOwner struct {
+	userID    string
+	expiresAt time.Time
+}
+
+// conversationOwnerCache remembers who owns a conversation. Ownership never changes
+// once a conversation is created, so it is safe to cache on every instance. Whether
+// the conversation is in the trash or purged can change on any instance, so that is
+// looked up on every access.
+type conversationOwnerCache struct {
+	mu        sync.Mutex
+	owners    map[string]cachedOwner
+	ttl       time.Duration
+	lastSweep time.Time
+	lookup    func(conversationID string) (conversationOwner, error)
+	isDeleted func(conversationID string) (bool, error)
+}
+
+var conversationOwners = &conversationOwnerCache{
+	owners:    make(map[string]cachedOwner),
+	ttl:       conversationOwnerTTL,
+	lookup:    lookupConversationOwner,
+	isDeleted: db.IsConversationDeleted,
+}
+
+func lookupConversationOwner(conversationID string) (conversationOwner, error) {
+	conversation, err := db.GetByID(conversationID)
+	if err != nil {
+		return conversationOwner{}, err
+	}
+	return conversationOwner{userID: conversation.UserID, deleted: conversation.DeletedAt != nil}, nil
+}
+
+// owner returns who owns the conversation and whether it is currently deleted.
+// Missing conversations return sql.ErrNoRows.
+func (cache *conversationOwnerCache) owner(conversationID string) (conversationOwner, error) {
+	now := time.Now()
+
+	cache.mu.Lock()
+	entry, ok := cache.owners[conversationID]
+	if ok && !now.Before(entry.expiresAt) {
+		delete(cache.owners, conversationID)
+		ok = false
+	}
+	cache.mu.Unlock()
+
+	if ok {
+		deleted, err := cache.isDeleted(conversationID)
+		if err != nil {
+			return conversationOwner{}, err
+		}
+		return conversationOwner{userID: entry.userID, deleted: deleted}, nil
+	}
+
+	owner, err := cache.lookup(conversationID)
+	if err != nil {
+		return conversationOwner{}, err
+	}
+
+	cache.mu.Lock()
+	cache.owners[conversationID] = cachedOwner{userID: owner.userID, expiresAt: now.Add(cache.ttl)}
+	if now.Sub(cache.lastSweep) >= cache.ttl {
+		cache.sweep(now)
+	}
+	cache.mu.Unlock()
+
+	return owner, nil
+}
+
+// sweep drops expired entries so the cache only holds recently used conversations.
+// The caller must hold mu.
+func (cache *conversationOwnerCache) sweep(now time.Time) {
+	for conversationID, entry := range cache.owners {
+		if !now.Before(entry.expiresAt) {
+			delete(cache.owners, conversationID)
+		}
+	}
+	cache.lastSweep = now
+}
+
+// authorizeConversation checks that userID owns conversationID. It returns the HTTP
+// status to respond with alongside ErrConversationNotFound or ErrConversationForbidden,
+// or http.StatusOK and a nil error when access is allowed. Soft-deleted conversations
+// are reported as not found.
+func authorizeConversation(userID string, conversationID string) (int, error) {
+	return checkConversationAccess(userID, conversationID, false)
+}
+
+// authorizeDeletedConversation is authorizeConversation for endpoints that operate on
+// conversations in the trash, such as restore
+func authorizeDeletedConversation(userID string, conversationID string) (int, error) {
+	return checkConversationAccess(userID, conversationID, true)
+}
+
+func checkConversationAccess(userID string, conversationID string, allowDeleted bool) (int, error) {
+	if _, err := uuid.Parse(conversationID); err != nil {
+		return http.StatusNotFound, ErrConversationNotFound
+	}
+
+	owner, err := conversationOwners.owner(conversationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return http.StatusNotFound, ErrConversationNotFound
+		}
+		logger.Get().Error("error fetching conversation owner",
+			zap.String("conversation_id", conversationID),
+			zap.Error(err))
+		return http.StatusInternalServerError, err
+	}
+
+	if owner.userID != userID {
+		logger.Get().Warn("cross-user conversation access denied",
+			zap.String("user_id", userID),
+			zap.String("conversation_id", conversationID))
+		return http.StatusForbidden, ErrConversationForbidden
+	}
+
+	if owner.deleted && !allowDeleted {
+		return http.StatusNotFound, ErrConversationNotFound
+	}
+
+	return http.StatusOK, nil
+}
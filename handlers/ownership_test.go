@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"database/sql"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	ownerID          = "owner-user"
+	otherUserID      = "other-user"
+	ownedID          = "6f1d2c3b-0a4e-4b8f-9c7d-1e2f3a4b5c6d"
+	deletedID        = "7a2e3d4c-1b5f-4c9a-8d6e-2f3a4b5c6d7e"
+	unknownID        = "8b3f4e5d-2c6a-4dab-9e7f-3a4b5c6d7e8f"
+	testJWTSecret    = "test-secret"
+	testSupabaseURL  = "https://supabase.test"
+	sseTokenLifetime = time.Hour
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	if err := logger.Init(false, logger.ErrorLevel); err != nil {
+		panic("failed to initialize logger: " + err.Error())
+	}
+	os.Exit(m.Run())
+}
+
+// stubConversationOwners replaces the owner lookup so no database is needed.
+// Conversations missing from owners don't exist.
+func stubConversationOwners(t *testing.T, owners map[string]conversationOwner) {
+	t.Helper()
+
+	lookup := func(conversationID string) (conversationOwner, error) {
+		owner, ok := owners[conversationID]
+		if !ok {
+			return conversationOwner{}, sql.ErrNoRows
+		}
+		return owner, nil
+	}
+
+	original := conversationOwners
+	conversationOwners = &conversationOwnerCache{
+		owners: make(map[string]cachedOwner),
+		ttl:    conversationOwnerTTL,
+		lookup: lookup,
+		isDeleted: func(conversationID string) (bool, error) {
+			owner, err := lookup(conversationID)
+			return owner.deleted, err
+		},
+	}
+	t.Cleanup(func() { conversationOwners = original })
+}
+
+func newOwnershipRouter(userID string) *gin.Engine {
+	router := gin.New()
+
+	api := router.Group("/api", func(c *gin.Context) {
+		c.Set("user", &models.SupabaseClaims{Sub: userID})
+		c.Next()
+	})
+	api.POST("/chat/message/list", HandleGetMessagesByConversationID)
+	api.POST("/chat/message/send", HandleSendMessage)
+
+	router.GET("/sse/:conversationID", HandleSSE)
+	return router
+}
+
+func sseToken(t *testing.T, userID string) string {
+	t.Helper()
+
+	claims := &models.SupabaseClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testSupabaseURL + "/auth/v1",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(sseTokenLifetime)),
+		},
+		Sub: userID,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testJWTSecret))
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func TestConversationAccessIsDenied(t *testing.T) {
+	t.Setenv("SUPABASE_JWT_SECRET", testJWTSecret)
+	t.Setenv("SUPABASE_URL", testSupabaseURL)
+
+	tests := []struct {
+		name           string
+		userID         string
+		conversationID string
+		want           int
+	}{
+		{"another user's conversation", otherUserID, ownedID, http.StatusForbidden},
+		{"another user's deleted conversation", otherUserID, deletedID, http.StatusForbidden},
+		{"unknown conversation", ownerID, unknownID, http.StatusNotFound},
+		{"deleted conversation", ownerID, deletedID, http.StatusNotFound},
+		{"malformed conversation ID", ownerID, "not-a-uuid", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stubConversationOwners(t, map[string]conversationOwner{
+				ownedID:   {userID: ownerID},
+				deletedID: {userID: ownerID, deleted: true},
+			})
+			router := newOwnershipRouter(tt.userID)
+			body := `{"conversation_id":"` + tt.conversationID + `","message":"hello"}`
+
+			requests := map[string]*http.Request{
+				"message list": httptest.NewRequest(http.MethodPost, "/api/chat/message/list", strings.NewReader(body)),
+				"message send": httptest.NewRequest(http.MethodPost, "/api/chat/message/send", strings.NewReader(body)),
+				"sse":          httptest.NewRequest(http.MethodGet, "/sse/"+tt.conversationID+"?token="+sseToken(t, tt.userID), nil),
+			}
+
+			for endpoint, req := range requests {
+				req.Header.Set("Content-Type", "application/json")
+				rec := httptest.NewRecorder()
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != tt.want {
+					t.Errorf("%s: got status %d, want %d (body %s)", endpoint, rec.Code, tt.want, rec.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestConversationOwnerCacheSeesDeletes(t *testing.T) {
+	owners := map[string]conversationOwner{ownedID: {userID: ownerID}}
+	stubConversationOwners(t, owners)
+
+	if status, err := authorizeConversation(ownerID, ownedID); err != nil {
+		t.Fatalf("owner denied: status %d, %v", status, err)
+	}
+
+	// A delete made elsewhere is seen even though the owner is cached
+	owners[ownedID] = conversationOwner{userID: ownerID, deleted: true}
+	if status, err := authorizeConversation(ownerID, ownedID); status != http.StatusNotFound {
+		t.Fatalf("got status %d (%v), want %d once deleted", status, err, http.StatusNotFound)
+	}
+	if status, _ := authorizeDeletedConversation(ownerID, ownedID); status != http.StatusOK {
+		t.Fatalf("restore check got status %d, want %d", status, http.StatusOK)
+	}
+
+	// And so is a purge
+	delete(owners, ownedID)
+	if status, err := authorizeDeletedConversation(ownerID, ownedID); status != http.StatusNotFound {
+		t.Fatalf("got status %d (%v), want %d once purged", status, err, http.StatusNotFound)
+	}
+}
+
+func TestConversationOwnerCacheEvictsExpired(t *testing.T) {
+	owners := map[string]conversationOwner{
+		ownedID:   {userID: ownerID},
+		deletedID: {userID: ownerID, deleted: true},
+	}
+	stubConversationOwners(t, owners)
+
+	if _, err := conversationOwners.owner(ownedID); err != nil {
+		t.Fatalf("looking up owner: %v", err)
+	}
+
+	// Age the entry past its TTL; the next lookup of another conversation sweeps it
+	conversationOwners.owners[ownedID] = cachedOwner{userID: ownerID, expiresAt: time.Now().Add(-time.Second)}
+	conversationOwners.lastSweep = time.Now().Add(-2 * conversationOwnerTTL)
+
+	if _, err := conversationOwners.owner(deletedID); err != nil {
+		t.Fatalf("looking up owner: %v", err)
+	}
+	if _, ok := conversationOwners.owners[ownedID]; ok {
+		t.Error("expired entry was not evicted")
+	}
+	if len(conversationOwners.owners) != 1 {
+		t.Errorf("cache holds %d entries, want 1", len(conversationOwners.owners))
+	}
+}
@@ -1,12 +1,23 @@
 package handlers
 
 import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"finance-chatbot/api/db"
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"finance-chatbot/api/qdrant"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/plaid/plaid-go/v37/plaid"
@@ -17,6 +28,14 @@ var (
 	PlaidClient *plaid.APIClient
 )
 
+// CreateLinkTokenRequest is optional. Investments and Liabilities ask for consent to
+// read brokerage and retirement holdings, and credit card, student loan and mortgage
+// details, on institutions that support them.
+type CreateLinkTokenRequest struct {
+	Investments bool `json:"investments"`
+	Liabilities bool `json:"liabilities"`
+}
+
 type CreateUpdateLinkTokenRequest struct {
 	AccessToken string `json:"access_token" binding:"required"`
 }
@@ -32,6 +51,10 @@ type HandleSuccessfulPlaidItemUpdateRequest struct {
 	ItemID string `json:"item_id" binding:"required"`
 }
 
+type RemovePlaidItemRequest struct {
+	ItemID string `json:"item_id" binding:"required"`
+}
+
 func CreateLinkToken(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -47,6 +70,13 @@ func CreateLinkToken(c *gin.Context) {
 		return
 	}
 
+	var req CreateLinkTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	user_data, err := db.GetUserByID(claims.Sub)
 
 	if err != nil {
@@ -91,6 +121,17 @@ func CreateLinkToken(c *gin.Context) {
 	)
 	linkTokenRequest.SetUserToken(plaidUserToken)
 	linkTokenRequest.SetProducts([]plaid.Products{plaid.PRODUCTS_TRANSACTIONS})
+	// Optional so institutions without these products can still be linked
+	var optionalProducts []plaid.Products
+	if req.Investments {
+		optionalProducts = append(optionalProducts, plaid.PRODUCTS_INVESTMENTS)
+	}
+	if req.Liabilities {
+		optionalProducts = append(optionalProducts, plaid.PRODUCTS_LIABILITIES)
+	}
+	if len(optionalProducts) > 0 {
+		linkTokenRequest.SetOptionalProducts(optionalProducts)
+	}
 	linkTokenRequest.SetWebhook(os.Getenv("PLAID_WEBHOOK_URL"))
 
 	logger.Get().Debug("creating link token",
@@ -230,7 +271,7 @@ func ExchangePublicToken(c *gin.Context) {
 	}
 
 	if existingItem != nil {
-		err = db.UpdatePlaidItemStatus(existingItem.ItemID, "active")
+		err = db.TransitionItemStatus(existingItem.ItemID, models.ItemStatusHealthy, "", "relinked")
 		if err != nil {
 			logger.Get().Error("error updating existing item",
 				zap.String("item_id", existingItem.ItemID),
@@ -267,6 +308,10 @@ func ExchangePublicToken(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		}
 
+		// Holdings and debts go into conversation contexts, so fetch them now
+		// rather than waiting for the first webhooks
+		go syncNewItemProducts(&models.PlaidItem{UserID: claims.Sub, ItemID: itemId, AccessToken: accessToken})
+
 		logger.Get().Info("created new plaid item",
 			zap.String("item_id", exchangeResponse.GetItemId()),
 			zap.String("user_id", claims.Sub))
@@ -439,11 +484,20 @@ func ProvisionTransactionsJob(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// HandlePlaidWebhook records the webhook and applies it unless an identical one was
+// applied in the last plaidDedupeWindow. Failures return 500 so Plaid retries.
 func HandlePlaidWebhook(c *gin.Context) {
 	logger.Get().Debug("Received Plaid webhook")
 
+	body, err := c.GetRawData()
+	if err != nil {
+		logger.Get().Error("error reading webhook body", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
+		return
+	}
+
 	var webhook models.GenericPlaidWebhook
-	if err := c.ShouldBindJSON(&webhook); err != nil {
+	if err := json.Unmarshal(body, &webhook); err != nil {
 		logger.Get().Error("error parsing generic webhook", zap.Error(err))
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook payload"})
 		return
@@ -455,36 +509,207 @@ func HandlePlaidWebhook(c *gin.Context) {
 		zap.String("webhook_item_id", webhook.ItemID),
 	)
 
+	// Plaid webhooks have no ID, so identical bodies are treated as the same event
+	hash := sha256.Sum256(body)
+	eventType := webhook.WebhookType + "." + webhook.WebhookCode
+
+	stored, duplicate, err := db.RecordWebhookEvent(models.WebhookSourcePlaid, hex.EncodeToString(hash[:]), eventType, body, plaidDedupeWindow)
+	if err != nil {
+		logger.Get().Error("error recording Plaid webhook", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record webhook"})
+		return
+	}
+	if duplicate {
+		logger.Get().Info("Duplicate Plaid webhook received",
+			zap.String("event_type", eventType),
+			zap.String("item_id", webhook.ItemID),
+			zap.String("status", string(stored.Status)))
+	}
+
+	if _, err := processWebhookEvent(c.Request.Context(), stored, false); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process webhook"})
+		return
+	}
+
+	logger.Get().Info("Plaid webhook processed successfully")
+	c.JSON(http.StatusOK, gin.H{"status": "received"})
+}
+
+// applyPlaidWebhook updates the item the webhook is about
+func applyPlaidWebhook(ctx context.Context, webhook models.GenericPlaidWebhook) error {
 	switch webhook.WebhookType {
 	case "ITEM":
 		switch webhook.WebhookCode {
 		case "ERROR":
-			if err := db.UpdateItemStatus(webhook.ItemID, models.ItemStatusError); err != nil {
-				logger.Get().Error("failed to update item status to ERROR", zap.Error(err))
-			} else {
-				logger.Get().Info("Updated item status to ERROR", zap.String("item_id", webhook.ItemID))
+			status := models.ItemStatusError
+			if webhookErrorCode(webhook) == "ITEM_LOGIN_REQUIRED" {
+				status = models.ItemStatusLoginRequired
 			}
+			return transitionItemStatus(webhook, status)
 
 		case "LOGIN_REPAIRED":
 			logger.Get().Info("Item login repaired", zap.String("item_id", webhook.ItemID))
+			return transitionItemStatus(webhook, models.ItemStatusHealthy)
+
+		case "PENDING_EXPIRATION":
+			return transitionItemStatus(webhook, models.ItemStatusPendingExpiration)
+
+		case "PENDING_DISCONNECT":
+			return transitionItemStatus(webhook, models.ItemStatusPendingDisconnect)
+
+		case "USER_PERMISSION_REVOKED":
+			return transitionItemStatus(webhook, models.ItemStatusRevoked)
 
-			if err := db.UpdateItemStatus(webhook.ItemID, models.ItemStatusHealthy); err != nil {
-				logger.Get().Error("failed to update item status to HEALTHY", zap.Error(err))
+		case "USER_ACCOUNT_REVOKED":
+			return removeRevokedAccount(ctx, webhook)
+
+		case "NEW_ACCOUNTS_AVAILABLE":
+			err := db.SetNewAccountsAvailable(webhook.ItemID, true)
+			if errors.Is(err, sql.ErrNoRows) {
+				logger.Get().Warn("Ignoring webhook for unknown item", zap.String("item_id", webhook.ItemID))
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("failed to flag new accounts available: %w", err)
+			}
+			logger.Get().Info("Flagged new accounts available", zap.String("item_id", webhook.ItemID))
+
+		case "WEBHOOK_UPDATE_ACKNOWLEDGED":
+			if code := webhookErrorCode(webhook); code != "" {
+				logger.Get().Warn("Webhook update acknowledged with error",
+					zap.String("item_id", webhook.ItemID),
+					zap.String("new_webhook_url", webhook.NewWebhookURL),
+					zap.String("error_code", code))
 			} else {
-				logger.Get().Info("Updated item status to HEALTHY", zap.String("item_id", webhook.ItemID))
+				logger.Get().Info("Webhook update acknowledged",
+					zap.String("item_id", webhook.ItemID),
+					zap.String("new_webhook_url", webhook.NewWebhookURL))
 			}
+
 		default:
 			logger.Get().Info("Unhandled ITEM webhook code", zap.String("webhook_code", webhook.WebhookCode))
 		}
+	case "TRANSACTIONS":
+		switch webhook.WebhookCode {
+		case "TRANSACTIONS_REMOVED":
+			return removeWebhookTransactions(ctx, webhook)
+		default:
+			logger.Get().Info("Unhandled TRANSACTIONS webhook code", zap.String("webhook_code", webhook.WebhookCode))
+		}
+	case "HOLDINGS":
+		switch webhook.WebhookCode {
+		case "DEFAULT_UPDATE":
+			return holdingSnapshots.syncWebhookItem(ctx, webhook)
+		default:
+			logger.Get().Info("Unhandled HOLDINGS webhook code", zap.String("webhook_code", webhook.WebhookCode))
+		}
+	case "LIABILITIES":
+		switch webhook.WebhookCode {
+		case "DEFAULT_UPDATE":
+			return liabilitySnapshots.syncWebhookItem(ctx, webhook)
+		default:
+			logger.Get().Info("Unhandled LIABILITIES webhook code", zap.String("webhook_code", webhook.WebhookCode))
+		}
 	default:
 		logger.Get().Info("Unhandled webhook type", zap.String("webhook_type", webhook.WebhookType))
 	}
 
-	logger.Get().Info("Plaid webhook processed successfully")
-	c.JSON(http.StatusOK, gin.H{"status": "received"})
+	return nil
+}
+
+func webhookErrorCode(webhook models.GenericPlaidWebhook) string {
+	if webhook.Error == nil {
+		return ""
+	}
+	return webhook.Error.ErrorCode
+}
+
+// transitionItemStatus moves the webhook's item to status. Webhooks for items we no
+// longer have are ignored, since retrying them can't succeed.
+func transitionItemStatus(webhook models.GenericPlaidWebhook, status models.ItemStatus) error {
+	reason := webhook.WebhookCode
+	if webhook.Reason != "" {
+		reason += ": " + webhook.Reason
+	}
+
+	err := db.TransitionItemStatus(webhook.ItemID, status, webhookErrorCode(webhook), reason)
+	if errors.Is(err, sql.ErrNoRows) {
+		logger.Get().Warn("Ignoring webhook for unknown item", zap.String("item_id", webhook.ItemID))
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update item %s to %s: %w", webhook.ItemID, status, err)
+	}
+
+	logger.Get().Info("Updated item status",
+		zap.String("item_id", webhook.ItemID),
+		zap.String("status", string(status)))
+	return nil
+}
+
+// removeWebhookTransactions deletes the vectors of transactions Plaid reports as
+// removed, so they no longer turn up in search
+func removeWebhookTransactions(ctx context.Context, webhook models.GenericPlaidWebhook) error {
+	item, err := db.GetPlaidItemByItemID(webhook.ItemID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		logger.Get().Warn("Ignoring webhook for unknown item", zap.String("item_id", webhook.ItemID))
+		return nil
+	}
+
+	if err := TransactionIndexer.Remove(ctx, webhook.RemovedTransactions); err != nil {
+		return err
+	}
+
+	logger.Get().Info("Removed transactions",
+		zap.String("item_id", webhook.ItemID),
+		zap.Int("transaction_count", len(webhook.RemovedTransactions)))
+	return nil
+}
+
+// removeRevokedAccount drops an account the user stopped sharing. The item itself
+// stays linked.
+func removeRevokedAccount(ctx context.Context, webhook models.GenericPlaidWebhook) error {
+	item, err := db.GetPlaidItemByItemID(webhook.ItemID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		logger.Get().Warn("Ignoring webhook for unknown item", zap.String("item_id", webhook.ItemID))
+		return nil
+	}
+
+	if err := qdrant.DeleteTransactionsByAccountID(ctx, webhook.AccountID); err != nil {
+		return err
+	}
+
+	if err := mongodb.RemoveAccountsFromContexts(ctx, item.UserID, "", []string{webhook.AccountID}); err != nil {
+		return err
+	}
+
+	logger.Get().Info("Removed revoked account",
+		zap.String("item_id", webhook.ItemID),
+		zap.String("account_id", webhook.AccountID))
+	return nil
 }
 
 func HandleSuccessfulPlaidItemUpdate(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
 	var req HandleSuccessfulPlaidItemUpdateRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		logger.Get().Error("error binding JSON", zap.Error(err))
@@ -492,31 +717,293 @@ func HandleSuccessfulPlaidItemUpdate(c *gin.Context) {
 		return
 	}
 
+	item, err := db.GetPlaidItemByItemID(req.ItemID)
+	if err != nil {
+		logger.Get().Error("error fetching plaid item",
+			zap.String("item_id", req.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if item == nil || item.UserID != claims.Sub {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
 	logger.Get().Info("Item login repaired", zap.String("item_id", req.ItemID))
 
-	if err := db.UpdateItemStatus(req.ItemID, models.ItemStatusHealthy); err != nil {
-		logger.Get().Error("failed to update item status to HEALTHY", zap.Error(err))
+	if err := db.TransitionItemStatus(req.ItemID, models.ItemStatusHealthy, "", "update_mode"); err != nil {
+		logger.Get().Error("failed to update item status to healthy", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	logger.Get().Info("Updated item status to HEALTHY", zap.String("item_id", req.ItemID))
+	// Update mode is also where the user picks up new accounts
+	if err := db.SetNewAccountsAvailable(req.ItemID, false); err != nil {
+		logger.Get().Error("failed to clear new accounts available", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("Updated item status to healthy", zap.String("item_id", req.ItemID))
 
 	c.JSON(http.StatusOK, gin.H{"status": "success"})
 }
 
+// HandleRemovePlaidItem disconnects a single bank. The item is removed from Plaid
+// first so the user stops being billed for it even if later cleanup fails, and the
+// plaid_items row, which keeps the item's account IDs, goes last so a failed cleanup
+// can be retried. Transactions are only
+// stored as Qdrant vectors, so deleting those removes them.
+func HandleRemovePlaidItem(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req RemovePlaidItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := db.GetPlaidItemByItemID(req.ItemID)
+	if err != nil {
+		logger.Get().Error("error fetching plaid item",
+			zap.String("item_id", req.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if item == nil || item.UserID != claims.Sub {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+		return
+	}
+
+	// Account IDs have to be read before the item is removed from Plaid, and are kept
+	// so a retry after Plaid has forgotten the item can still clean up by account
+	accountIDs := item.AccountIDs
+	if accountIDs == nil {
+		accountIDs = getItemAccountIDs(c.Request.Context(), item)
+		if err := db.SetPlaidItemAccountIDs(item.ItemID, accountIDs); err != nil {
+			logger.Get().Error("error recording item account IDs",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := DeletePlaidItems(c, []string{item.AccessToken}); err != nil {
+		logger.Get().Error("error removing item from plaid",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to remove item from Plaid"})
+		return
+	}
+
+	if err := removeItemTransactions(c.Request.Context(), item.ItemID, accountIDs); err != nil {
+		logger.Get().Error("error deleting item transactions from Qdrant",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Item removed but its transactions could not be deleted"})
+		return
+	}
+
+	if err := mongodb.RemoveAccountsFromContexts(c.Request.Context(), claims.Sub, item.ItemID, accountIDs); err != nil {
+		logger.Get().Error("error removing item accounts from conversation contexts",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Item removed but its accounts could not be removed from conversations"})
+		return
+	}
+
+	if err := mongodb.DeleteInvestmentSnapshot(c.Request.Context(), item.ItemID); err != nil {
+		logger.Get().Error("error deleting item investment snapshot",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Item removed but its holdings could not be deleted"})
+		return
+	}
+
+	if err := mongodb.DeleteLiabilitiesSnapshot(c.Request.Context(), item.ItemID); err != nil {
+		logger.Get().Error("error deleting item liabilities snapshot",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Item removed but its debts could not be deleted"})
+		return
+	}
+
+	if err := db.DeletePlaidItem(item.ItemID, claims.Sub); err != nil {
+		logger.Get().Error("error deleting plaid item",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("plaid item removed",
+		zap.String("user_id", claims.Sub),
+		zap.String("item_id", item.ItemID))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// getItemAccountIDs lists the item's account IDs. It returns nil if Plaid can't be
+// reached, for example when the item needs the user to log in again.
+func getItemAccountIDs(ctx context.Context, item *models.PlaidItem) []string {
+	req := plaid.NewAccountsGetRequest(item.AccessToken)
+	resp, _, err := PlaidClient.PlaidApi.AccountsGet(ctx).AccountsGetRequest(*req).Execute()
+	if err != nil {
+		logger.Get().Warn("failed to get accounts for item",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		return nil
+	}
+
+	accountIDs := make([]string, 0, len(resp.GetAccounts()))
+	for _, account := range resp.GetAccounts() {
+		accountIDs = append(accountIDs, account.GetAccountId())
+	}
+	return accountIDs
+}
+
+// removeItemTransactions deletes an item's vectors. Points written without an item_id
+// are caught by deleting each of the item's accounts as well.
+func removeItemTransactions(ctx context.Context, itemID string, accountIDs []string) error {
+	if err := qdrant.DeleteTransactionsByItemID(ctx, itemID); err != nil {
+		return err
+	}
+	for _, accountID := range accountIDs {
+		if err := qdrant.DeleteTransactionsByAccountID(ctx, accountID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeletePlaidItems removes items from Plaid. An item Plaid no longer knows about
+// counts as removed, so a retry after a partial failure succeeds.
 func DeletePlaidItems(c *gin.Context, accessTokens []string) error {
 
 	for _, token := range accessTokens {
 		request := plaid.NewItemRemoveRequest(token)
 		_, _, err := PlaidClient.PlaidApi.ItemRemove(c.Request.Context()).ItemRemoveRequest(*request).Execute()
 		if err != nil {
-			return fmt.Errorf("failed to remove Plaid item with token %s: %w", token, err)
+			if plaidErrorCode(err) == "ITEM_NOT_FOUND" {
+				logger.Get().Warn("plaid item already removed")
+				continue
+			}
+			return fmt.Errorf("failed to remove Plaid item: %w", err)
+		}
+	}
+	return nil
+}
+
+// productUnavailableErrorCodes mean an item has no data for a product, because the
+// institution or the user's accounts don't support it or the user didn't consent,
+// as opposed to a failure worth retrying
+var productUnavailableErrorCodes = map[string]bool{
+	"NO_INVESTMENT_ACCOUNTS":      true,
+	"NO_LIABILITY_ACCOUNTS":       true,
+	"PRODUCTS_NOT_SUPPORTED":      true,
+	"ADDITIONAL_CONSENT_REQUIRED": true,
+	"INVALID_PRODUCT":             true,
+}
+
+// newItemSyncTimeout bounds the background sync of a newly linked item
+const newItemSyncTimeout = time.Minute
+
+// syncNewItemProducts records which products a newly linked item has and fetches
+// its holdings and debts, outside the request
+func syncNewItemProducts(item *models.PlaidItem) {
+	ctx, cancel := context.WithTimeout(context.Background(), newItemSyncTimeout)
+	defer cancel()
+
+	if err := refreshItemProducts(ctx, item); err != nil {
+		logger.Get().Warn("failed to get products for new item",
+			zap.String("item_id", item.ItemID),
+			zap.Error(err))
+		return
+	}
+
+	if item.HasProduct(string(plaid.PRODUCTS_INVESTMENTS)) {
+		if _, err := syncItemHoldings(ctx, item); err != nil {
+			logger.Get().Warn("failed to sync holdings for new item",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+		}
+	}
+
+	if item.HasProduct(string(plaid.PRODUCTS_LIABILITIES)) {
+		if _, err := syncItemLiabilities(ctx, item); err != nil {
+			logger.Get().Warn("failed to sync liabilities for new item",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+		}
+	}
+}
+
+// refreshItemProducts looks up the products enabled on an item and stores them.
+// Optional products the user consented to in Link are among them once Plaid has added
+// them. Calling a product's endpoint would add it too, so only what Plaid reports
+// counts.
+func refreshItemProducts(ctx context.Context, item *models.PlaidItem) error {
+	req := plaid.NewItemGetRequest(item.AccessToken)
+	resp, _, err := PlaidClient.PlaidApi.ItemGet(ctx).ItemGetRequest(*req).Execute()
+	if err != nil {
+		return fmt.Errorf("failed to get item %s: %w", item.ItemID, err)
+	}
+
+	plaidItem := resp.GetItem()
+	products := []string{}
+	for _, product := range append(plaidItem.GetProducts(), plaidItem.GetBilledProducts()...) {
+		if !slices.Contains(products, string(product)) {
+			products = append(products, string(product))
 		}
 	}
+
+	if err := db.SetPlaidItemProducts(item.ItemID, products); err != nil {
+		return err
+	}
+	item.Products = products
 	return nil
 }
 
+// itemHasProduct reports whether product is enabled on an item, looking the item up
+// first when it was linked before products were recorded
+func itemHasProduct(ctx context.Context, item *models.PlaidItem, product plaid.Products) (bool, error) {
+	if item.Products == nil {
+		if err := refreshItemProducts(ctx, item); err != nil {
+			return false, err
+		}
+	}
+	return item.HasProduct(string(product)), nil
+}
+
+// plaidErrorCode returns the error_code of a Plaid API error, or "" for any other error
+func plaidErrorCode(err error) string {
+	var plaidErr *plaid.GenericOpenAPIError
+	if !errors.As(err, &plaidErr) {
+		return ""
+	}
+
+	var apiError models.PlaidError
+	if json.Unmarshal(plaidErr.Body(), &apiError) != nil {
+		return ""
+	}
+	return apiError.ErrorCode
+}
+
 func DeletePlaidUser(c *gin.Context) error {
 	user, exists := c.Get("user")
 	if !exists {
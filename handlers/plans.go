@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/planner"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// Account types that carry debt
+const (
+	accountTypeCredit = "credit"
+	accountTypeLoan   = "loan"
+)
+
+// DebtPayoffPlanRequest asks for a payoff plan. Order lists account IDs and is only
+// used by the custom strategy. APROverrides maps account IDs to annual percentages
+// and wins over what Plaid reports.
+type DebtPayoffPlanRequest struct {
+	MonthlyBudget float64            `json:"monthly_budget"`
+	Strategy      planner.Strategy   `json:"strategy"`
+	Order         []string           `json:"order"`
+	APROverrides  map[string]float64 `json:"apr_overrides"`
+}
+
+func HandleDebtPayoffPlan(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req DebtPayoffPlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.MonthlyBudget <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Monthly budget must be positive"})
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = planner.Avalanche
+	}
+	for accountID, apr := range req.APROverrides {
+		if apr < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "APR override for " + accountID + " is negative"})
+			return
+		}
+	}
+
+	items, err := db.GetPlaidItemsByUserID(claims.Sub)
+	if err != nil {
+		logger.Get().Error("error fetching plaid items",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	accounts, err := getAccounts(c, items)
+	if err != nil {
+		logger.Get().Error("error getting accounts",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Liabilities fill in APRs and minimums. Without them every APR must be overridden,
+	// which the missing_apr response asks for.
+	snapshots, err := liabilitySnapshots.load(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Warn("error getting liabilities for debt payoff plan",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+	}
+
+	if unknown := unknownOverrides(accounts, req.APROverrides); len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":            "apr_overrides lists accounts that aren't credit or loan accounts",
+			"unknown_accounts": unknown,
+		})
+		return
+	}
+
+	debts, missingAPR := payoffDebts(accounts, flattenDebts(snapshots), req.APROverrides)
+	if len(missingAPR) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":       "APR is unknown for some accounts, set it in apr_overrides",
+			"missing_apr": missingAPR,
+		})
+		return
+	}
+
+	plan, err := planner.Build(debts, req.MonthlyBudget, req.Strategy, req.Order, time.Now())
+	if err != nil {
+		if errors.Is(err, planner.ErrUnknownStrategy) ||
+			errors.Is(err, planner.ErrInvalidOrder) ||
+			errors.Is(err, planner.ErrBudgetTooLow) ||
+			errors.Is(err, planner.ErrNoPayoff) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		logger.Get().Error("error building debt payoff plan",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("built debt payoff plan",
+		zap.String("user_id", claims.Sub),
+		zap.String("strategy", string(plan.Strategy)),
+		zap.Int("debt_count", len(plan.Debts)),
+		zap.Int("months", plan.Months))
+
+	c.JSON(http.StatusOK, gin.H{"plan": plan})
+}
+
+// payoffDebts turns the user's credit and loan accounts into planner debts, taking
+// APRs and minimum payments from liabilities and then from overrides. It also returns
+// the IDs of accounts with a balance but no known APR.
+func payoffDebts(accounts []models.Account, liabilities []models.Debt, aprOverrides map[string]float64) ([]planner.Debt, []string) {
+	byAccount := make(map[string]models.Debt, len(liabilities))
+	for _, debt := range liabilities {
+		byAccount[debt.AccountID] = debt
+	}
+
+	debts := []planner.Debt{}
+	missingAPR := []string{}
+	for _, account := range accounts {
+		if account.Type != accountTypeCredit && account.Type != accountTypeLoan {
+			continue
+		}
+		if account.Balances.Current <= 0 {
+			continue
+		}
+
+		debt := planner.Debt{
+			ID:      account.AccountID,
+			Name:    account.Name,
+			Balance: account.Balances.Current,
+		}
+
+		aprKnown := false
+		if liability, ok := byAccount[account.AccountID]; ok {
+			if liability.APR != nil {
+				debt.APR = *liability.APR
+				aprKnown = true
+			}
+			if liability.MinimumPayment != nil {
+				debt.MinimumPayment = *liability.MinimumPayment
+			}
+		}
+		if apr, ok := aprOverrides[account.AccountID]; ok {
+			debt.APR = apr
+			aprKnown = true
+		}
+
+		if !aprKnown {
+			missingAPR = append(missingAPR, account.AccountID)
+			continue
+		}
+		debts = append(debts, debt)
+	}
+
+	return debts, missingAPR
+}
+
+// unknownOverrides returns the sorted account IDs in aprOverrides that don't belong
+// to one of the user's credit or loan accounts
+func unknownOverrides(accounts []models.Account, aprOverrides map[string]float64) []string {
+	debtAccounts := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		if account.Type == accountTypeCredit || account.Type == accountTypeLoan {
+			debtAccounts[account.AccountID] = true
+		}
+	}
+
+	unknown := []string{}
+	for accountID := range aprOverrides {
+		if !debtAccounts[accountID] {
+			unknown = append(unknown, accountID)
+		}
+	}
+	slices.Sort(unknown)
+	return unknown
+}
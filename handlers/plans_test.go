@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"finance-chatbot/api/models"
+	"slices"
+	"testing"
+)
+
+func TestUnknownOverrides(t *testing.T) {
+	accounts := []models.Account{
+		{AccountID: "card", Type: accountTypeCredit, Balances: models.Balances{Current: 1200}},
+		{AccountID: "paid-off-card", Type: accountTypeCredit},
+		{AccountID: "mortgage", Type: accountTypeLoan, Balances: models.Balances{Current: 250000}},
+		{AccountID: "checking", Type: "depository", Balances: models.Balances{Current: 3000}},
+	}
+
+	tests := []struct {
+		name      string
+		overrides map[string]float64
+		want      []string
+	}{
+		{"no overrides", nil, []string{}},
+		{"credit and loan accounts", map[string]float64{"card": 19.99, "paid-off-card": 24.99, "mortgage": 6.5}, []string{}},
+		{"missing and non-debt accounts", map[string]float64{"card": 19.99, "typo": 10, "checking": 1}, []string{"checking", "typo"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unknownOverrides(accounts, tt.overrides); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 50
+	snippetRadius      = 80
+)
+
+type SearchMessagesRequest struct {
+	Query string     `json:"query" binding:"required"`
+	From  *time.Time `json:"from"`
+	To    *time.Time `json:"to"`
+	Limit int64      `json:"limit"`
+}
+
+func HandleSearchMessages(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req SearchMessagesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.From != nil && req.To != nil && req.From.After(*req.To) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to"})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	if limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+
+	// Scope the search to the caller's conversations; assistant messages are not
+	// guaranteed to carry a user_id, so filtering on it would hide bot replies
+	titles, err := db.GetConversationTitlesByUserID(claims.Sub)
+	if err != nil {
+		logger.Get().Error("error fetching conversations for search",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversationIDs := make([]string, 0, len(titles))
+	for id := range titles {
+		conversationIDs = append(conversationIDs, id)
+	}
+
+	results, err := mongodb.SearchMessages(c.Request.Context(), mongodb.MessageSearchParams{
+		Query:           req.Query,
+		ConversationIDs: conversationIDs,
+		From:            req.From,
+		To:              req.To,
+		Limit:           limit,
+	})
+	if err != nil {
+		logger.Get().Error("error searching messages",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	for i := range results {
+		results[i].Title = titles[results[i].ConversationID]
+		results[i].Snippet = buildSnippet(results[i].Text, req.Query)
+	}
+
+	logger.Get().Info("message search completed",
+		zap.String("user_id", claims.Sub),
+		zap.Int("result_count", len(results)))
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// buildSnippet returns the part of text surrounding the first query term it contains
+func buildSnippet(text string, query string) string {
+	runes := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	start := -1
+	for _, term := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		if idx := indexRunes(lower, []rune(term)); idx >= 0 && (start < 0 || idx < start) {
+			start = idx
+		}
+	}
+	if start < 0 {
+		start = 0
+	}
+
+	from := max(start-snippetRadius, 0)
+	to := min(start+snippetRadius, len(runes))
+
+	snippet := strings.TrimSpace(string(runes[from:to]))
+	if from > 0 {
+		snippet = "…" + snippet
+	}
+	if to < len(runes) {
+		snippet = snippet + "…"
+	}
+	return snippet
+}
+
+func indexRunes(haystack []rune, needle []rune) int {
+	if len(needle) == 0 {
+		return -1
+	}
+	for i := 0; i+len(needle) <= len(haystack); i++ {
+		match := true
+		for j := range needle {
+			if haystack[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShareExpiryDays = 30
+	maxShareExpiryDays     = 365
+	shareTokenBytes        = 32
+)
+
+type CreateShareRequest struct {
+	ConversationID string `json:"conversation_id" binding:"required"`
+	ExpiresInDays  int    `json:"expires_in_days"`
+}
+
+type RevokeShareRequest struct {
+	ShareID string `json:"share_id" binding:"required"`
+}
+
+func HandleCreateShare(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if status, err := authorizeConversation(claims.Sub, req.ConversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	days := req.ExpiresInDays
+	if days <= 0 {
+		days = defaultShareExpiryDays
+	}
+	if days > maxShareExpiryDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days cannot exceed 365"})
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		logger.Get().Error("error generating share token", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	share, err := db.CreateConversationShare(req.ConversationID, claims.Sub, hashShareToken(token), time.Now().AddDate(0, 0, days))
+	if err != nil {
+		logger.Get().Error("error creating conversation share",
+			zap.String("conversation_id", req.ConversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("conversation share created",
+		zap.String("user_id", claims.Sub),
+		zap.String("conversation_id", req.ConversationID),
+		zap.String("share_id", share.ID.String()))
+
+	// The token is only ever returned here; the database keeps its hash
+	c.JSON(http.StatusOK, gin.H{
+		"share": share,
+		"token": token,
+		"url":   getClientURL() + "/shared/" + token,
+	})
+}
+
+func HandleListShares(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	shares, err := db.ListActiveSharesByUserID(claims.Sub)
+	if err != nil {
+		logger.Get().Error("error listing conversation shares",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shares": shares})
+}
+
+func HandleRevokeShare(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req RevokeShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := uuid.Parse(req.ShareID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+		return
+	}
+
+	if err := db.RevokeConversationShare(req.ShareID, claims.Sub); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share not found"})
+			return
+		}
+		logger.Get().Error("error revoking conversation share",
+			zap.String("share_id", req.ShareID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("conversation share revoked",
+		zap.String("user_id", claims.Sub),
+		zap.String("share_id", req.ShareID))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetSharedConversation serves a shared conversation without authentication
+func HandleGetSharedConversation(c *gin.Context) {
+	token := c.Param("token")
+
+	share, err := db.GetActiveShareByTokenHash(hashShareToken(token))
+	if err != nil {
+		logger.Get().Error("error fetching conversation share", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared conversation"})
+		return
+	}
+	if share == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+
+	conversationID := share.ConversationID.String()
+
+	conversation, err := db.GetByID(conversationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+			return
+		}
+		logger.Get().Error("error fetching shared conversation",
+			zap.String("conversation_id", conversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared conversation"})
+		return
+	}
+	if conversation.DeletedAt != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Share not found or expired"})
+		return
+	}
+
+	conversationContext, err := mongodb.GetConversationContext(c.Request.Context(), conversationID)
+	if err != nil {
+		logger.Get().Error("error fetching shared conversation context",
+			zap.String("conversation_id", conversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared conversation"})
+		return
+	}
+
+	messages, _, err := mongodb.GetMessagesByConversationID(c.Request.Context(), conversationID, mongodb.MessageListParams{})
+	if err != nil {
+		logger.Get().Error("error fetching shared conversation messages",
+			zap.String("conversation_id", conversationID),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load shared conversation"})
+		return
+	}
+
+	shared := models.SharedConversation{
+		Title:     conversation.Title,
+		CreatedAt: conversation.CreatedAt,
+		Context:   redactContext(conversationContext),
+		Messages:  make([]models.SharedMessage, 0, len(messages)),
+	}
+	for _, message := range messages {
+		shared.Messages = append(shared.Messages, models.SharedMessage{
+			Text:      message.Text,
+			Sender:    message.Sender,
+			Timestamp: message.Timestamp,
+		})
+	}
+
+	c.JSON(http.StatusOK, shared)
+}
+
+// redactContext drops the user's name and income, identifiers, account masks and
+// balances from a context
+func redactContext(conversationContext *models.Context) *models.SharedContext {
+	if conversationContext == nil {
+		return nil
+	}
+
+	shared := &models.SharedContext{
+		SavingsGoal:        conversationContext.SavingsGoal,
+		AdditionalExpenses: conversationContext.AdditionalExpenses,
+		Accounts:           make([]models.SharedAccount, 0, len(conversationContext.Accounts)),
+	}
+	for _, account := range conversationContext.Accounts {
+		shared.Accounts = append(shared.Accounts, models.SharedAccount{
+			Name:    account.Name,
+			Type:    account.Type,
+			Subtype: account.Subtype,
+		})
+	}
+	return shared
+}
+
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
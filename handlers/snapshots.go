@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"context"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"time"
+
+	"github.com/plaid/plaid-go/v37/plaid"
+	"go.uber.org/zap"
+)
+
+// itemSnapshots describes an optional Plaid product whose data is stored as one
+// snapshot per item and refreshed by webhooks, with maxAge as the fallback
+type itemSnapshots[S any] struct {
+	// name is what the product's data is called in log messages
+	name      string
+	product   plaid.Products
+	maxAge    time.Duration
+	stored    func(ctx context.Context, userID string) ([]S, error)
+	sync      func(ctx context.Context, item *models.PlaidItem) (S, error)
+	itemID    func(snapshot S) string
+	updatedAt func(snapshot S) int64
+}
+
+// load returns a snapshot for each of the user's items with the product, fetching
+// those that are missing or older than maxAge. An item that can't be fetched keeps
+// its stored snapshot, if it has one.
+func (s itemSnapshots[S]) load(ctx context.Context, userID string) ([]S, error) {
+	items, err := db.GetPlaidItemsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := s.stored(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	byItem := make(map[string]S, len(stored))
+	for _, snapshot := range stored {
+		byItem[s.itemID(snapshot)] = snapshot
+	}
+
+	staleBefore := time.Now().Add(-s.maxAge).Unix()
+	snapshots := make([]S, 0, len(items))
+	for _, item := range items {
+		enabled, err := itemHasProduct(ctx, item, s.product)
+		if err != nil {
+			logger.Get().Warn("failed to get products for item",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+			continue
+		}
+		if !enabled {
+			continue
+		}
+
+		snapshot, found := byItem[item.ItemID]
+		if !found || s.updatedAt(snapshot) < staleBefore {
+			fresh, err := s.sync(ctx, item)
+			if err != nil {
+				logger.Get().Warn("failed to sync "+s.name+" for item",
+					zap.String("item_id", item.ItemID),
+					zap.Error(err))
+			} else {
+				snapshot, found = fresh, true
+			}
+		}
+
+		if found {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+
+	return snapshots, nil
+}
+
+// syncWebhookItem refreshes the snapshot of the item a webhook is about
+func (s itemSnapshots[S]) syncWebhookItem(ctx context.Context, webhook models.GenericPlaidWebhook) error {
+	item, err := db.GetPlaidItemByItemID(webhook.ItemID)
+	if err != nil {
+		return err
+	}
+	if item == nil {
+		logger.Get().Warn("Ignoring webhook for unknown item", zap.String("item_id", webhook.ItemID))
+		return nil
+	}
+
+	enabled, err := itemHasProduct(ctx, item, s.product)
+	if err != nil || !enabled {
+		return err
+	}
+
+	_, err = s.sync(ctx, item)
+	return err
+}
@@ -17,7 +17,8 @@ type SSEMessage struct {
 }
 
 func HandleSSE(c *gin.Context) {
-	if err := authenticateSSE(c); err != nil {
+	claims, err := authenticateSSE(c)
+	if err != nil {
 		logger.Get().Error("authentication failed", zap.Error(err))
 		c.JSON(http.StatusUnauthorized, gin.H{"error": fmt.Sprintf("Unauthorized: %v", err)})
 		return
@@ -25,8 +26,13 @@ func HandleSSE(c *gin.Context) {
 
 	conversationID := c.Param("conversationID")
 
+	if status, err := authorizeConversation(claims.Sub, conversationID); err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
 	clientStream := &sse.ClientStream{
-		Messages:      make(chan string, 100),
+		Messages:      make(chan sse.Event, 100),
 		BufferFlushed: make(chan struct{}), // NEW: signal for buffered message flushing
 	}
 
@@ -60,19 +66,28 @@ func HandleSSE(c *gin.Context) {
 	// Stream loop
 	c.Stream(func(w io.Writer) bool {
 		select {
-		case msg, ok := <-clientStream.Messages:
+		case event, ok := <-clientStream.Messages:
 			if !ok {
 				return false
 			}
 
-			payload, err := json.Marshal(SSEMessage{Message: msg})
+			var data any = SSEMessage{Message: event.Message}
+			if event.Name != "" {
+				data = event.Data
+			}
+
+			payload, err := json.Marshal(data)
 			if err != nil {
 				logger.Get().Error("failed to marshal SSE message",
 					zap.Error(err),
-					zap.String("message", msg))
+					zap.String("event", event.Name),
+					zap.String("message", event.Message))
 				return false
 			}
 
+			if event.Name != "" {
+				c.Writer.Write([]byte("event: " + event.Name + "\n"))
+			}
 			c.Writer.Write([]byte("data: " + string(payload) + "\n\n"))
 			flusher.Flush()
 			return true
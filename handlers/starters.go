@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/starters"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultStarterCount = 4
+	maxStarterCount     = 10
+)
+
+type GetStartersRequest struct {
+	Limit int `json:"limit"`
+}
+
+// HandleGetStarters returns ranked prompts for starting a new conversation, based on
+// the user's linked accounts, profile and last 30 days of spending
+func HandleGetStarters(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req GetStartersRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultStarterCount
+	}
+	if limit > maxStarterCount {
+		limit = maxStarterCount
+	}
+
+	items, err := db.GetPlaidItemsByUserID(claims.Sub)
+	if err != nil {
+		logger.Get().Error("error fetching plaid items",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	input := starters.Input{Now: time.Now()}
+
+	// Starters are best effort: missing accounts, profile or transactions just mean
+	// fewer rules apply
+	if input.Accounts, err = getAccounts(c, items); err != nil {
+		logger.Get().Warn("error getting accounts for starters",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+	}
+
+	if input.UserInfo, err = getUserInfo(c, claims.Sub); err != nil {
+		logger.Get().Warn("error getting user info for starters",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+	}
+
+	if input.Transactions, err = getTransactionsSince(c.Request.Context(), items, input.Now.Add(-starters.RecentSpendingWindow)); err != nil {
+		logger.Get().Warn("error getting transactions for starters",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"starters": starters.Generate(input, limit)})
+}
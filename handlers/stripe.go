@@ -2,10 +2,13 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"finance-chatbot/api/db"
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/middleware"
 	"finance-chatbot/api/models"
+	"finance-chatbot/api/mongodb"
+	"fmt"
 	"net/http"
 	"os"
 
@@ -57,12 +60,7 @@ func HandleCreateStripeSession(c *gin.Context) {
 		return
 	}
 
-	var clientURL string
-	if os.Getenv("ENV") == "production" {
-		clientURL = os.Getenv("CLIENT_PROD_URL")
-	} else {
-		clientURL = os.Getenv("CLIENT_DEV_URL")
-	}
+	clientURL := getClientURL()
 
 	params := &stripe.CheckoutSessionParams{
 		Customer:   stripe.String(cust.ID),
@@ -97,7 +95,10 @@ func HandleCreateStripeSession(c *gin.Context) {
 	})
 }
 
-// func HandleStripeWebhook(w http.ResponseWriter, r *http.Request) {
+// HandleStripeWebhook records the event and applies it unless an earlier delivery
+// already did. Failures return 500 so Stripe retries, except for events whose data
+// can't be parsed: a retry would fail the same way, so those are acknowledged and
+// left failed for replay.
 func HandleStripeWebhook(c *gin.Context) {
 	eventRaw, exists := c.Get(middleware.StripeEventKey)
 	if !exists {
@@ -111,6 +112,42 @@ func HandleStripeWebhook(c *gin.Context) {
 		return
 	}
 
+	payloadRaw, _ := c.Get(middleware.StripePayloadKey)
+	payload, ok := payloadRaw.([]byte)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid event payload"})
+		return
+	}
+
+	stored, duplicate, err := db.RecordWebhookEvent(models.WebhookSourceStripe, event.ID, string(event.Type), payload, 0)
+	if err != nil {
+		logger.Get().Error("Error recording Stripe event", zap.String("event_id", event.ID), zap.Error(err))
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	if duplicate {
+		logger.Get().Info("Duplicate Stripe event received",
+			zap.String("event_id", event.ID),
+			zap.String("status", string(stored.Status)))
+	}
+
+	if _, err := processWebhookEvent(c.Request.Context(), stored, false); err != nil {
+		if errors.Is(err, errMalformedWebhook) {
+			logger.Get().Warn("Acknowledging Stripe event that can't be parsed",
+				zap.String("event_id", event.ID),
+				zap.String("event_type", string(event.Type)))
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
+
+// applyStripeEvent updates the user the event is about
+func applyStripeEvent(event stripe.Event) error {
 	var stripeID string
 
 	switch event.Type {
@@ -118,40 +155,30 @@ func HandleStripeWebhook(c *gin.Context) {
 		logger.Get().Info("Checkout session completed", zap.String("event_id", event.ID))
 		var session stripe.CheckoutSession
 		if err := json.Unmarshal(event.Data.Raw, &session); err != nil {
-			logger.Get().Error("Error parsing session", zap.Error(err))
-			c.Status(http.StatusBadRequest)
-			return
+			return fmt.Errorf("%w: error parsing session: %w", errMalformedWebhook, err)
 		}
 		stripeID = session.Customer.ID
 		logger.Get().Debug("User IDs", zap.String("stripe_id", stripeID))
 		if err := db.UpdateTrialStatusByStripeID(stripeID, true); err != nil {
-			logger.Get().Error("Error updating Stripe ID", zap.Error(err))
-			c.Status(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("error updating trial status: %w", err)
 		}
 
 	case "customer.subscription.created":
 		logger.Get().Info("Customer subscription created", zap.String("event_type", string(event.Type)), zap.String("event_id", event.ID))
 		var subscription stripe.Subscription
 		if err := json.Unmarshal(event.Data.Raw, &subscription); err != nil {
-			logger.Get().Error("Error parsing subscription", zap.Error(err))
-			c.Status(http.StatusBadRequest)
-			return
+			return fmt.Errorf("%w: error parsing subscription: %w", errMalformedWebhook, err)
 		}
 		stripeID = subscription.Customer.ID
 		if err := db.UpdateStatusByStripeID(stripeID, models.UserStatusTrial, &subscription.ID); err != nil {
-			logger.Get().Error("Error updating user status", zap.Error(err))
-			c.Status(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("error updating user status: %w", err)
 		}
 
 	case "invoice.paid", "invoice.payment_failed":
 		logger.Get().Info("Invoice event received", zap.String("event_type", string(event.Type)), zap.String("event_id", event.ID))
 		var invoice stripe.Invoice
 		if err := json.Unmarshal(event.Data.Raw, &invoice); err != nil {
-			logger.Get().Error("Error parsing invoice", zap.Error(err))
-			c.Status(http.StatusBadRequest)
-			return
+			return fmt.Errorf("%w: error parsing invoice: %w", errMalformedWebhook, err)
 		}
 		stripeID = invoice.Customer.ID
 
@@ -161,9 +188,7 @@ func HandleStripeWebhook(c *gin.Context) {
 		}
 
 		if err := db.UpdateStatusByStripeID(stripeID, status, nil); err != nil {
-			logger.Get().Error("Error updating user status", zap.Error(err))
-			c.Status(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("error updating user status: %w", err)
 		}
 
 	case "customer.subscription.deleted":
@@ -171,25 +196,21 @@ func HandleStripeWebhook(c *gin.Context) {
 
 		var subscription stripe.Subscription
 		if err := json.Unmarshal(event.Data.Raw, &subscription); err != nil {
-			logger.Get().Error("Error parsing subscription", zap.Error(err))
-			c.Status(http.StatusBadRequest)
-			return
+			return fmt.Errorf("%w: error parsing subscription: %w", errMalformedWebhook, err)
 		}
 
 		logger.Get().Debug("Parsed subscription", zap.String("customer_id", subscription.Customer.ID))
 
 		stripeID = subscription.Customer.ID
 		if err := db.UpdateStatusByStripeID(stripeID, models.UserStatusInactive, nil); err != nil {
-			logger.Get().Error("Error updating user status", zap.Error(err))
-			c.Status(http.StatusInternalServerError)
-			return
+			return fmt.Errorf("error updating user status: %w", err)
 		}
 
 	default:
 		logger.Get().Info("Unhandled event type", zap.String("event_type", string(event.Type)))
 	}
 
-	c.Status(http.StatusOK)
+	return nil
 }
 
 func HandleGetUser(c *gin.Context) {
@@ -239,13 +260,22 @@ func HandleDeleteSubscription(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 
-	accessTokens, err := db.DeletePlaidItemsByUserID(claims.Sub)
+	items, err := db.DeletePlaidItemsByUserID(claims.Sub)
 
 	if err != nil {
 		logger.Get().Error("Error deleting items from postegres", zap.Error(err))
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 
+	// Account IDs have to be read before the items are removed from Plaid
+	accessTokens := make([]string, 0, len(items))
+	for _, item := range items {
+		accessTokens = append(accessTokens, item.AccessToken)
+		if item.AccountIDs == nil {
+			item.AccountIDs = getItemAccountIDs(c.Request.Context(), item)
+		}
+	}
+
 	err = DeletePlaidItems(c, accessTokens)
 
 	if err != nil {
@@ -253,6 +283,31 @@ func HandleDeleteSubscription(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 
+	transactionsRemoved := true
+	for _, item := range items {
+		if err := removeItemTransactions(c.Request.Context(), item.ItemID, item.AccountIDs); err != nil {
+			logger.Get().Error("Error deleting item transactions from Qdrant",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+			transactionsRemoved = false
+		}
+		if err := mongodb.DeleteInvestmentSnapshot(c.Request.Context(), item.ItemID); err != nil {
+			logger.Get().Error("Error deleting item investment snapshot",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+		}
+		if err := mongodb.DeleteLiabilitiesSnapshot(c.Request.Context(), item.ItemID); err != nil {
+			logger.Get().Error("Error deleting item liabilities snapshot",
+				zap.String("item_id", item.ItemID),
+				zap.Error(err))
+		}
+	}
+
+	if !transactionsRemoved {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Subscription cancelled but some transactions could not be deleted"})
+		return
+	}
+
 	c.JSON(http.StatusOK, result)
 }
 
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/llm"
+	"finance-chatbot/api/logger"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+var ErrTagNotFound = errors.New("tag not found")
+
+// suggestTags asks the LLM for tags that fit a new conversation, preferring the
+// user's existing tags. It returns an empty list if anything goes wrong.
+func suggestTags(userID string, message string) []string {
+	existing, err := db.Tags.ListByUserID(userID)
+	if err != nil {
+		logger.Get().Warn("error listing tags for suggestions",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return []string{}
+	}
+
+	names := make([]string, 0, len(existing))
+	for _, tag := range existing {
+		names = append(names, tag.Name)
+	}
+
+	suggestions, err := llm.SuggestTags(message, names)
+	if err != nil {
+		logger.Get().Warn("error suggesting tags",
+			zap.String("user_id", userID),
+			zap.Error(err))
+		return []string{}
+	}
+	return suggestions
+}
+
+func HandleCreateTag(c *gin.Context) { tagHandlers.create(c) }
+
+func HandleListTags(c *gin.Context) { tagHandlers.list(c) }
+
+func HandleUpdateTag(c *gin.Context) { tagHandlers.update(c) }
+
+func HandleDeleteTag(c *gin.Context) { tagHandlers.delete(c) }
+
+func HandleAddConversationTag(c *gin.Context) { tagHandlers.attach(c) }
+
+func HandleRemoveConversationTag(c *gin.Context) { tagHandlers.detach(c) }
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"finance-chatbot/api/qdrant"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTransactionSearchLimit = 10
+	maxTransactionSearchLimit     = 50
+	maxTransactionQueryLength     = 500
+)
+
+type SearchTransactionsRequest struct {
+	Query    string  `json:"query"`
+	Limit    int     `json:"limit"`
+	MinScore float32 `json:"min_score"`
+}
+
+// HandleSearchTransactions finds the user's transactions that best match a
+// natural-language query, such as "everything related to my trip to Denver"
+func HandleSearchTransactions(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	var req SearchTransactionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := strings.TrimSpace(req.Query)
+	if query == "" || utf8.RuneCountInString(query) > maxTransactionQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "query must be between 1 and 500 characters"})
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultTransactionSearchLimit
+	}
+	if limit > maxTransactionSearchLimit {
+		limit = maxTransactionSearchLimit
+	}
+
+	vector, err := TransactionIndexer.EmbedQuery(c.Request.Context(), query)
+	if err != nil {
+		logger.Get().Error("error embedding transaction search query",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to process search query"})
+		return
+	}
+
+	results, err := qdrant.SearchTransactions(c.Request.Context(), claims.Sub, vector, uint64(limit), req.MinScore)
+	if err != nil {
+		logger.Get().Error("error searching transactions",
+			zap.String("user_id", claims.Sub),
+			zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transactions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"transactions": results})
+}
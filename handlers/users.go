@@ -98,6 +98,22 @@ func HandleDeleteUser(c *gin.Context) {
 		logger.Get().Info("Deleted conversation messages from MongoDB", zap.String("user_id", claims.Sub))
 	}
 
+	err = mongodb.DeleteInvestmentsByUserID(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Error("Error deleting investment snapshots", zap.Error(err), zap.String("user_id", claims.Sub))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting investment snapshots"})
+	} else {
+		logger.Get().Info("Deleted investment snapshots from MongoDB", zap.String("user_id", claims.Sub))
+	}
+
+	err = mongodb.DeleteLiabilitiesByUserID(c.Request.Context(), claims.Sub)
+	if err != nil {
+		logger.Get().Error("Error deleting liabilities snapshots", zap.Error(err), zap.String("user_id", claims.Sub))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error deleting liabilities snapshots"})
+	} else {
+		logger.Get().Info("Deleted liabilities snapshots from MongoDB", zap.String("user_id", claims.Sub))
+	}
+
 	err = qdrant.DeleteTransactionsByUserID(claims.Sub)
 	if err != nil {
 		logger.Get().Error("Error deleting transactions from Qdrant", zap.Error(err), zap.String("user_id", claims.Sub))
@@ -9,13 +9,16 @@ import (
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/models"
 	"finance-chatbot/api/mongodb"
+	"finance-chatbot/api/portfolio"
 	"fmt"
 	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/plaid/plaid-go/v37/plaid"
+	"go.mongodb.org/mongo-driver/v2/bson"
 	"go.uber.org/zap"
 )
 
@@ -47,6 +50,26 @@ func createConversationContext(c *gin.Context, userID string, conversationID str
 		Accounts:       accounts,
 	}
 
+	// Holdings and debts come from the stored snapshots; fetching them from Plaid
+	// would slow down every new conversation
+	snapshots, err := mongodb.GetInvestmentSnapshots(c.Request.Context(), userID)
+	if err != nil {
+		logger.Get().Error("error getting investment snapshots",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	} else {
+		conversationContext.Investments = portfolio.Summarize(snapshots)
+	}
+
+	liabilities, err := mongodb.GetLiabilitiesSnapshots(c.Request.Context(), userID)
+	if err != nil {
+		logger.Get().Error("error getting liabilities snapshots",
+			zap.String("user_id", userID),
+			zap.Error(err))
+	} else {
+		conversationContext.Debts = flattenDebts(liabilities)
+	}
+
 	userInfo, err := getUserInfo(c, userID)
 	if err != nil {
 		logger.Get().Error("error getting user info",
@@ -68,8 +91,16 @@ func createConversationContext(c *gin.Context, userID string, conversationID str
 }
 
 func getTransactions(c *gin.Context, items []*models.PlaidItem) ([]models.Transaction, error) {
+	return getTransactionsSince(c.Request.Context(), items, time.Now().AddDate(0, 0, -transactionHistoryDays))
+}
+
+// transactionsPageSize is the largest page /transactions/get allows
+const transactionsPageSize = 500
+
+// getTransactionsSince fetches every transaction on the items dated on or after since
+func getTransactionsSince(ctx context.Context, items []*models.PlaidItem, since time.Time) ([]models.Transaction, error) {
 	endDate := time.Now().Format("2006-01-02")
-	startDate := time.Now().AddDate(0, 0, -730).Format("2006-01-02")
+	startDate := since.Format("2006-01-02")
 	transactions := []models.Transaction{}
 
 	logger.Get().Debug("fetching transactions",
@@ -80,63 +111,78 @@ func getTransactions(c *gin.Context, items []*models.PlaidItem) ([]models.Transa
 		logger.Get().Debug("fetching transactions for plaid item",
 			zap.String("access_token", plaidItem.AccessToken))
 
-		request := plaid.TransactionsGetRequest{
-			AccessToken: plaidItem.AccessToken,
-			StartDate:   startDate,
-			EndDate:     endDate,
-		}
+		// Plaid returns at most transactionsPageSize transactions per call, so page
+		// through until the reported total is reached
+		var offset int32
+		for {
+			request := plaid.TransactionsGetRequest{
+				AccessToken: plaidItem.AccessToken,
+				StartDate:   startDate,
+				EndDate:     endDate,
+				Options: &plaid.TransactionsGetRequestOptions{
+					Count:  plaid.PtrInt32(transactionsPageSize),
+					Offset: plaid.PtrInt32(offset),
+				},
+			}
 
-		result, _, err := PlaidClient.PlaidApi.TransactionsGet(c.Request.Context()).TransactionsGetRequest(request).Execute()
-		if err != nil {
-			if plaidErr, ok := err.(*plaid.GenericOpenAPIError); ok {
-				body := plaidErr.Body()
-
-				var plaidAPIError models.PlaidError
-				if unmarshalErr := json.Unmarshal(body, &plaidAPIError); unmarshalErr != nil {
-					logger.Get().Error("failed to unmarshal Plaid error body",
-						zap.String("raw_body", string(body)),
-						zap.Error(unmarshalErr),
-						zap.Error(plaidErr))
-					return nil, fmt.Errorf("failed to unmarshal Plaid error: %w", unmarshalErr)
+			result, _, err := PlaidClient.PlaidApi.TransactionsGet(ctx).TransactionsGetRequest(request).Execute()
+			if err != nil {
+				if plaidErr, ok := err.(*plaid.GenericOpenAPIError); ok {
+					body := plaidErr.Body()
+
+					var plaidAPIError models.PlaidError
+					if unmarshalErr := json.Unmarshal(body, &plaidAPIError); unmarshalErr != nil {
+						logger.Get().Error("failed to unmarshal Plaid error body",
+							zap.String("raw_body", string(body)),
+							zap.Error(unmarshalErr),
+							zap.Error(plaidErr))
+						return nil, fmt.Errorf("failed to unmarshal Plaid error: %w", unmarshalErr)
+					}
+
+					// Log full structured error
+					logger.Get().Error("plaid API error",
+						zap.String("error_type", plaidAPIError.ErrorType),
+						zap.String("error_code", plaidAPIError.ErrorCode),
+						zap.String("error_message", plaidAPIError.ErrorMessage),
+						zap.Error(plaidErr),
+					)
+
+					// Return structured error
+					return nil, &models.PlaidError{
+						ErrorType:    plaidAPIError.ErrorType,
+						ErrorCode:    plaidAPIError.ErrorCode,
+						ErrorMessage: plaidAPIError.ErrorMessage,
+						RequestId:    plaidAPIError.RequestId,
+					}
 				}
+				// fallback for non-Plaid errors
+				logger.Get().Error("unexpected error fetching transactions", zap.Error(err))
+				return nil, fmt.Errorf("failed to fetch transactions: %w", err)
+			}
 
-				// Log full structured error
-				logger.Get().Error("plaid API error",
-					zap.String("error_type", plaidAPIError.ErrorType),
-					zap.String("error_code", plaidAPIError.ErrorCode),
-					zap.String("error_message", plaidAPIError.ErrorMessage),
-					zap.Error(plaidErr),
-				)
-
-				// Return structured error
-				return nil, &models.PlaidError{
-					ErrorType:    plaidAPIError.ErrorType,
-					ErrorCode:    plaidAPIError.ErrorCode,
-					ErrorMessage: plaidAPIError.ErrorMessage,
-					RequestId:    plaidAPIError.RequestId,
+			plaidTransactions := result.GetTransactions()
+			logger.Get().Info("transactions fetched successfully",
+				zap.String("access_token", plaidItem.AccessToken),
+				zap.Int("transaction_count", len(plaidTransactions)))
+
+			for _, t := range plaidTransactions {
+				transaction := models.Transaction{
+					TransactionID: t.GetTransactionId(),
+					AccountID:     t.GetAccountId(),
+					Date:          t.GetDate(),
+					Amount:        t.GetAmount(),
+					Name:          t.GetName(),
+					MerchantName:  t.GetMerchantName(),
+					Category:      t.GetPersonalFinanceCategory().Primary,
+					Pending:       t.GetPending(),
 				}
+				transactions = append(transactions, transaction)
 			}
-			// fallback for non-Plaid errors
-			logger.Get().Error("unexpected error fetching transactions", zap.Error(err))
-			return nil, fmt.Errorf("failed to fetch transactions: %w", err)
-		}
 
-		plaidTransactions := result.GetTransactions()
-		logger.Get().Info("transactions fetched successfully",
-			zap.String("access_token", plaidItem.AccessToken),
-			zap.Int("transaction_count", len(plaidTransactions)))
-
-		for _, t := range plaidTransactions {
-			transaction := models.Transaction{
-				TransactionID: t.GetTransactionId(),
-				Date:          t.GetDate(),
-				Amount:        t.GetAmount(),
-				Name:          t.GetName(),
-				MerchantName:  t.GetMerchantName(),
-				Category:      t.GetPersonalFinanceCategory().Primary,
-				Pending:       t.GetPending(),
+			offset += int32(len(plaidTransactions))
+			if len(plaidTransactions) == 0 || offset >= result.GetTotalTransactions() {
+				break
 			}
-			transactions = append(transactions, transaction)
 		}
 	}
 
@@ -166,6 +212,7 @@ func getAccounts(c *gin.Context, items []*models.PlaidItem) ([]models.Account, e
 
 			account := models.Account{
 				AccountID:    acct.GetAccountId(),
+				ItemID:       item.ItemID,
 				Name:         acct.GetName(),
 				OfficialName: acct.GetOfficialName(),
 				Type:         string(acct.GetType()),
@@ -212,9 +259,12 @@ func getUserInfo(c *gin.Context, userID string) (*models.UserInfo, error) {
 }
 
 func processUserMessage(ctx context.Context, userId string, msg *models.Message) error {
+	msg.ID = bson.ObjectID{}
 	msg.UserID = userId
-	msg.Sender = "UserMessage"
-	msg.Timestamp = time.Now().Unix()
+	msg.Sender = models.SenderUser
+	msg.Timestamp = time.Now().UnixMilli()
+	msg.Sequence = 0
+	msg.Superseded = false
 
 	err := mongodb.CreateMessage(ctx, msg)
 	if err != nil {
@@ -224,6 +274,30 @@ func processUserMessage(ctx context.Context, userId string, msg *models.Message)
 		return fmt.Errorf("failed to create message: %w", err)
 	}
 
+	if err := db.TouchConversation(msg.ConversationID); err != nil {
+		logger.Get().Warn("failed to update conversation activity",
+			zap.String("conversation_id", msg.ConversationID),
+			zap.Error(err))
+	}
+
+	return produceUserMessage(ctx, userId, msg)
+}
+
+// produceUserMessage hands a stored user message to the AI service under a new
+// generation ID, reserving the sequence its reply is stored under
+func produceUserMessage(ctx context.Context, userId string, msg *models.Message) error {
+	msg.GenerationID = uuid.NewString()
+
+	replySequence, err := mongodb.NextMessageSequence(ctx, msg.ConversationID)
+	if err != nil {
+		logger.Get().Error("failed to reserve reply sequence",
+			zap.String("user_id", userId),
+			zap.String("conversation_id", msg.ConversationID),
+			zap.Error(err))
+		return fmt.Errorf("failed to reserve reply sequence: %w", err)
+	}
+	msg.ReplySequence = replySequence
+
 	messageBytes, err := json.Marshal(msg)
 	if err != nil {
 		logger.Get().Error("failed to marshal message",
@@ -243,11 +317,13 @@ func processUserMessage(ctx context.Context, userId string, msg *models.Message)
 	return nil
 }
 
-func authenticateSSE(c *gin.Context) error {
+// authenticateSSE validates the token passed in the query string, since EventSource
+// cannot set an Authorization header, and returns the caller's claims
+func authenticateSSE(c *gin.Context) (*models.SupabaseClaims, error) {
 	tokenString := c.DefaultQuery("token", "")
 	if tokenString == "" {
 		logger.Get().Error("missing or invalid token")
-		return fmt.Errorf("missing or invalid token")
+		return nil, fmt.Errorf("missing or invalid token")
 	}
 
 	claims := &models.SupabaseClaims{}
@@ -264,20 +340,20 @@ func authenticateSSE(c *gin.Context) error {
 
 	if err != nil {
 		logger.Get().Error("error parsing claims", zap.Error(err))
-		return err
+		return nil, err
 	}
 
 	if !token.Valid {
 		logger.Get().Error("invalid token")
-		return err
+		return nil, fmt.Errorf("invalid token")
 	}
 
 	if claims.Issuer != os.Getenv("SUPABASE_URL")+"/auth/v1" {
 		logger.Get().Error("invalid token issuer",
 			zap.String("issuer", claims.Issuer))
-		return err
+		return nil, fmt.Errorf("invalid token issuer")
 	}
-	return nil
+	return claims, nil
 }
 
 func provisionSaveTransactionsJob(userId string, itemId string, accessToken string, cursor *string) error {
@@ -318,6 +394,14 @@ func provisionSaveTransactionsJob(userId string, itemId string, accessToken stri
 	return nil
 }
 
+// getClientURL returns the base URL of the web client for the current environment
+func getClientURL() string {
+	if os.Getenv("ENV") == "production" {
+		return os.Getenv("CLIENT_PROD_URL")
+	}
+	return os.Getenv("CLIENT_DEV_URL")
+}
+
 func needsSync(lastSyncedAt sql.NullTime, syncStatus models.SyncStatus) bool {
 
 	if syncStatus == models.TransactionsJobInProgress {
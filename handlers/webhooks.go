@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stripe/stripe-go/v82"
+	"go.uber.org/zap"
+)
+
+// plaidDedupeWindow is how long an identical Plaid webhook counts as a redelivery.
+// Plaid stops retrying after a day, and a later identical body is a new event, such as
+// an item failing the same way a second time.
+const plaidDedupeWindow = 24 * time.Hour
+
+// errMalformedWebhook marks events whose payload can't be parsed. Applying them again
+// can't succeed, so they aren't worth a retry from the sender.
+var errMalformedWebhook = errors.New("malformed webhook payload")
+
+type ReplayWebhookEventRequest struct {
+	ID string `json:"id"`
+}
+
+// processWebhookEvent claims a stored event, applies it and records the outcome. It
+// reports false without error when the event was already processed or another
+// delivery is processing it. replay allows applying an event that was processed.
+func processWebhookEvent(ctx context.Context, event *models.WebhookEvent, replay bool) (bool, error) {
+	claimed, err := db.ClaimWebhookEvent(event.ID, replay)
+	if err != nil {
+		logger.Get().Error("failed to claim webhook event",
+			zap.String("id", event.ID.String()),
+			zap.Error(err))
+		return false, err
+	}
+	if !claimed {
+		logger.Get().Info("skipping webhook event already handled",
+			zap.String("id", event.ID.String()),
+			zap.String("source", event.Source),
+			zap.String("event_type", event.EventType))
+		return false, nil
+	}
+
+	processErr := applyWebhookEvent(ctx, event)
+	if processErr != nil {
+		logger.Get().Error("failed to process webhook event",
+			zap.String("id", event.ID.String()),
+			zap.String("source", event.Source),
+			zap.String("event_type", event.EventType),
+			zap.Error(processErr))
+	}
+
+	if err := db.FinishWebhookEvent(event.ID, processErr); err != nil {
+		logger.Get().Error("failed to record webhook event outcome",
+			zap.String("id", event.ID.String()),
+			zap.Error(err))
+		if processErr == nil {
+			return true, err
+		}
+	}
+	return true, processErr
+}
+
+// applyWebhookEvent decodes the stored payload and runs it through its source's handler
+func applyWebhookEvent(ctx context.Context, event *models.WebhookEvent) error {
+	switch event.Source {
+	case models.WebhookSourceStripe:
+		var stripeEvent stripe.Event
+		if err := json.Unmarshal(event.Payload, &stripeEvent); err != nil {
+			return fmt.Errorf("%w: error parsing Stripe event: %w", errMalformedWebhook, err)
+		}
+		return applyStripeEvent(stripeEvent)
+
+	case models.WebhookSourcePlaid:
+		var webhook models.GenericPlaidWebhook
+		if err := json.Unmarshal(event.Payload, &webhook); err != nil {
+			return fmt.Errorf("%w: error parsing Plaid webhook: %w", errMalformedWebhook, err)
+		}
+		return applyPlaidWebhook(ctx, webhook)
+
+	default:
+		return fmt.Errorf("unknown webhook source %q", event.Source)
+	}
+}
+
+// HandleReplayWebhookEvent applies a stored event again, whatever its status, through
+// the same code that handled it when it arrived. The response carries the event with
+// its new status and error.
+func HandleReplayWebhookEvent(c *gin.Context) {
+	var req ReplayWebhookEventRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		logger.Get().Error("error binding JSON", zap.Error(err))
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, err := uuid.Parse(req.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid event ID"})
+		return
+	}
+
+	event, err := db.GetWebhookEvent(id)
+	if err != nil {
+		logger.Get().Error("error fetching webhook event", zap.String("id", req.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if event == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Event not found"})
+		return
+	}
+
+	processed, err := processWebhookEvent(c.Request.Context(), event, true)
+	if !processed {
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusConflict, gin.H{"error": "Event is being processed"})
+		}
+		return
+	}
+
+	event, err = db.GetWebhookEvent(id)
+	if err != nil {
+		logger.Get().Error("error fetching webhook event", zap.String("id", req.ID), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	logger.Get().Info("webhook event replayed",
+		zap.String("id", req.ID),
+		zap.String("status", string(event.Status)))
+
+	// A failed replay is reported through the event's status and error
+	c.JSON(http.StatusOK, gin.H{"event": event})
+}
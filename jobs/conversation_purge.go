@@ -0,0 +1,105 @@
+package jobs
+
+import (
+	"context"
+	"finance-chatbot/api/db"
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/mongodb"
+	"os"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultConversationRetentionDays = 30
+	conversationPurgeBatchSize       = 100
+)
+
+// ConversationRetention is how long a soft-deleted conversation can still be restored.
+// It is read from CONVERSATION_RETENTION_DAYS and defaults to 30 days.
+func ConversationRetention() time.Duration {
+	days := defaultConversationRetentionDays
+	if value := os.Getenv("CONVERSATION_RETENTION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			logger.Get().Warn("invalid CONVERSATION_RETENTION_DAYS, using default",
+				zap.String("value", value),
+				zap.Int("default_days", defaultConversationRetentionDays))
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// RunConversationPurge permanently deletes conversations whose retention window has
+// passed, checking every interval until ctx is cancelled
+func RunConversationPurge(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := PurgeDeletedConversations(ctx); err != nil {
+			logger.Get().Error("error purging deleted conversations", zap.Error(err))
+		} else if purged > 0 {
+			logger.Get().Info("purged deleted conversations", zap.Int("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeDeletedConversations deletes every conversation that was soft-deleted before
+// the retention window. Mongo data is removed before the Postgres row so a failure
+// part way through leaves the row in the trash and the next run picks it up again.
+func PurgeDeletedConversations(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-ConversationRetention())
+	purged := 0
+
+	for {
+		ids, err := db.GetConversationIDsDeletedBefore(cutoff, conversationPurgeBatchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		failed := 0
+		for _, id := range ids {
+			if err := purgeConversation(ctx, id); err != nil {
+				logger.Get().Error("error purging conversation",
+					zap.String("conversation_id", id),
+					zap.Error(err))
+				failed++
+				continue
+			}
+			purged++
+		}
+
+		// Stop on a short batch, or when nothing in the batch could be purged so a
+		// persistent failure doesn't spin on the same rows
+		if len(ids) < conversationPurgeBatchSize || failed == len(ids) || ctx.Err() != nil {
+			return purged, ctx.Err()
+		}
+	}
+}
+
+func purgeConversation(ctx context.Context, conversationID string) error {
+	if err := mongodb.DeleteMessages(ctx, conversationID); err != nil {
+		return err
+	}
+	if err := mongodb.DeleteMessageCounter(ctx, conversationID); err != nil {
+		return err
+	}
+	if err := mongodb.DeleteFeedbackByConversationID(ctx, conversationID); err != nil {
+		return err
+	}
+	if err := mongodb.DeleteConversation(ctx, conversationID); err != nil {
+		return err
+	}
+	return db.DeleteConversation(conversationID)
+}
@@ -1,7 +1,9 @@
 package kafka
 
 import (
+	"encoding/json"
 	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
 	"finance-chatbot/api/worker"
 	"os"
 
@@ -12,6 +14,7 @@ import (
 const (
 	MessageTopic         string = "user_message"
 	TransactionsJobTopic string = "save_transactions"
+	CancelTopic          string = "generation_cancel"
 	GroupID              string = "ai-response-consumer"
 )
 
@@ -19,6 +22,9 @@ var (
 	MessageProducer *kafka.Producer
 	WorkerPool      *worker.WorkerPool
 	ResponseTopic   string = "ai_response" // THIS IS A CONSTANT NEVER CHANGE IT
+
+	// OnGenerationComplete, if set before StartKafkaConsumer, runs after each answer finishes
+	OnGenerationComplete func(models.AIResponse)
 )
 
 func InitProducer() error {
@@ -69,6 +75,30 @@ func ProduceMessage(topic string, message []byte) error {
 	return nil
 }
 
+// ProduceKeyedMessage produces a message with a partitioning key so that every
+// message for the same key lands on the same partition
+func ProduceKeyedMessage(topic string, key string, message []byte) error {
+	msg := &kafka.Message{
+		TopicPartition: kafka.TopicPartition{Topic: &topic, Partition: kafka.PartitionAny},
+		Key:            []byte(key),
+		Value:          message,
+	}
+
+	err := MessageProducer.Produce(msg, nil)
+	if err != nil {
+		logger.Get().Error("failed to produce message",
+			zap.String("topic", topic),
+			zap.String("key", key),
+			zap.Error(err))
+		return err
+	}
+
+	logger.Get().Debug("message produced successfully",
+		zap.String("topic", topic),
+		zap.String("key", key))
+	return nil
+}
+
 func StartKafkaConsumer() error {
 	// Get the Kafka username and password if they are set
 	username := os.Getenv("KAFKA_USERNAME")
@@ -108,6 +138,7 @@ func StartKafkaConsumer() error {
 
 	// Initialize worker pool with number of workers matching partitions
 	WorkerPool = worker.NewWorkerPool(numPartitions)
+	WorkerPool.OnComplete(OnGenerationComplete)
 	WorkerPool.Start()
 
 	consumerConfig := &kafka.ConfigMap{
@@ -136,10 +167,13 @@ func StartKafkaConsumer() error {
 		return err
 	}
 
-	err = consumer.Subscribe(ResponseTopic, nil)
+	// Responses and cancellations are both keyed by conversation. With the range
+	// assignor the same partition of each topic goes to the same consumer, so a
+	// cancel is seen by the instance that is streaming that conversation.
+	err = consumer.SubscribeTopics([]string{ResponseTopic, CancelTopic}, nil)
 	if err != nil {
-		logger.Get().Error("failed to subscribe to topic",
-			zap.String("topic", ResponseTopic),
+		logger.Get().Error("failed to subscribe to topics",
+			zap.Strings("topics", []string{ResponseTopic, CancelTopic}),
 			zap.Error(err))
 		return err
 	}
@@ -154,10 +188,15 @@ func StartKafkaConsumer() error {
 			msg, err := consumer.ReadMessage(-1)
 			if err == nil {
 				logger.Get().Debug("received message",
-					zap.String("topic", ResponseTopic),
+					zap.String("topic", *msg.TopicPartition.Topic),
 					zap.String("value", string(msg.Value)),
 					zap.Int32("partition", msg.TopicPartition.Partition))
 
+				if *msg.TopicPartition.Topic == CancelTopic {
+					handleCancelMessage(msg.Value)
+					continue
+				}
+
 				// Submit the message to the worker pool with its partition
 				WorkerPool.Submit(msg.Value, msg.TopicPartition.Partition)
 			} else {
@@ -169,3 +208,13 @@ func StartKafkaConsumer() error {
 	}()
 	return nil
 }
+
+func handleCancelMessage(value []byte) {
+	var cancel models.CancelGeneration
+	if err := json.Unmarshal(value, &cancel); err != nil {
+		logger.Get().Error("failed to unmarshal cancel message", zap.Error(err))
+		return
+	}
+
+	WorkerPool.Cancel(cancel.ConversationID, cancel.GenerationID)
+}
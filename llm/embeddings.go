@@ -0,0 +1,59 @@
+package llm
+
+import (
+	"fmt"
+	"os"
+)
+
+// defaultEmbeddingModel must match the model the transactions collection was embedded with
+const defaultEmbeddingModel = "text-embedding-3-small"
+
+type embeddingRequest struct {
+	Model string   `json:"model"`
+	Input []string `json:"input"`
+}
+
+type embeddingResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// EmbeddingModel returns the embedding model from EMBEDDING_MODEL, or the default
+func EmbeddingModel() string {
+	if model := os.Getenv("EMBEDDING_MODEL"); model != "" {
+		return model
+	}
+	return defaultEmbeddingModel
+}
+
+// CreateEmbeddings embeds each input and returns the vectors in the same order
+func CreateEmbeddings(inputs []string) ([][]float32, error) {
+	if len(inputs) == 0 {
+		return [][]float32{}, nil
+	}
+
+	var resp embeddingResponse
+	if err := postOpenAI(openaiEmbeddingsURL, embeddingRequest{Model: EmbeddingModel(), Input: inputs}, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Error != nil {
+		return nil, fmt.Errorf("error creating embeddings: %s", resp.Error.Message)
+	}
+	if len(resp.Data) != len(inputs) {
+		return nil, fmt.Errorf("expected %d embeddings, got %d", len(inputs), len(resp.Data))
+	}
+
+	vectors := make([][]float32, len(inputs))
+	for _, item := range resp.Data {
+		if item.Index < 0 || item.Index >= len(inputs) {
+			return nil, fmt.Errorf("embedding index %d out of range", item.Index)
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	return vectors, nil
+}
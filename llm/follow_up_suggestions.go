@@ -0,0 +1,51 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	minFollowUps = 2
+	maxFollowUps = 4
+)
+
+// SuggestFollowUps proposes two to four questions the user might ask next. financialContext
+// is a plain-text summary of the user's situation and transcript holds the most recent
+// turns of the conversation, oldest first.
+func SuggestFollowUps(financialContext string, transcript []Message) ([]string, error) {
+	messages := []Message{
+		{Role: "system", Content: fmt.Sprintf("You suggest follow-up questions for a personal finance assistant. "+
+			"Based on the user's financial situation and the conversation, write %d to %d short questions the user is likely to ask next, "+
+			"written in the user's voice and specific to their accounts, budget or savings goal. "+
+			"Reply with one question per line and nothing else.\n\nUser's financial situation:\n%s", minFollowUps, maxFollowUps, financialContext)},
+	}
+	messages = append(messages, transcript...)
+
+	content, err := chatCompletion(OpenAIRequest{
+		Model:       "gpt-3.5-turbo",
+		MaxTokens:   150,
+		Temperature: 0.5,
+		Messages:    messages,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := []string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "-*•0123456789.) "))
+		if line == "" {
+			continue
+		}
+		suggestions = append(suggestions, line)
+		if len(suggestions) == maxFollowUps {
+			break
+		}
+	}
+
+	if len(suggestions) < minFollowUps {
+		return nil, fmt.Errorf("expected at least %d follow-up suggestions, got %d", minFollowUps, len(suggestions))
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,84 @@
+package llm
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	openaiURL           = "https://api.openai.com/v1/chat/completions"
+	openaiEmbeddingsURL = "https://api.openai.com/v1/embeddings"
+)
+
+type OpenAIRequest struct {
+	Model       string    `json:"model"`
+	Messages    []Message `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature float64   `json:"temperature"`
+}
+
+type Message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type Choice struct {
+	Message Message `json:"message"`
+}
+
+type OpenAIResponse struct {
+	Choices []Choice `json:"choices"`
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// maxErrorBodyBytes caps how much of an error response is kept in the returned error
+const maxErrorBodyBytes = 4096
+
+// chatCompletion sends reqBody to the chat completions API and returns the content of
+// the first choice, or an empty string when there are no choices
+func chatCompletion(reqBody OpenAIRequest) (string, error) {
+	var openaiResp OpenAIResponse
+	if err := postOpenAI(openaiURL, reqBody, &openaiResp); err != nil {
+		return "", err
+	}
+
+	if len(openaiResp.Choices) == 0 {
+		return "", nil
+	}
+	return openaiResp.Choices[0].Message.Content, nil
+}
+
+// postOpenAI posts reqBody as JSON to an OpenAI endpoint and decodes the response into
+// out. Non-2xx responses are returned as errors carrying the response body.
+func postOpenAI(url string, reqBody any, out any) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+os.Getenv("OPENAI_API_KEY"))
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+		return fmt.Errorf("OpenAI returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
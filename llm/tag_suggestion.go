@@ -0,0 +1,55 @@
+package llm
+
+import (
+	"fmt"
+	"strings"
+)
+
+const maxSuggestedTags = 3
+
+// SuggestTags proposes up to three short tags for a conversation that starts with
+// userMessage. Names in existingTags are preferred so users don't end up with
+// near-duplicates; they are returned with the user's own spelling.
+func SuggestTags(userMessage string, existingTags []string) ([]string, error) {
+	existing := "none"
+	if len(existingTags) > 0 {
+		existing = strings.Join(existingTags, ", ")
+	}
+
+	content, err := chatCompletion(OpenAIRequest{
+		Model:       "gpt-3.5-turbo",
+		MaxTokens:   30,
+		Temperature: 0.2,
+		Messages: []Message{
+			{Role: "system", Content: fmt.Sprintf("You suggest topic tags for personal finance chat conversations. Reply with at most %d tags of one or two words each, separated by commas, and nothing else. Reuse the user's existing tags when they fit.", maxSuggestedTags)},
+			{Role: "user", Content: fmt.Sprintf("Existing tags: %s\nFirst message: %q", existing, userMessage)},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]string, len(existingTags))
+	for _, tag := range existingTags {
+		known[strings.ToLower(tag)] = tag
+	}
+
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, raw := range strings.Split(content, ",") {
+		tag := strings.TrimSpace(cleanString(raw))
+		key := strings.ToLower(tag)
+		if tag == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if name, ok := known[key]; ok {
+			tag = name
+		}
+		tags = append(tags, tag)
+		if len(tags) == maxSuggestedTags {
+			break
+		}
+	}
+	return tags, nil
+}
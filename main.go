@@ -2,8 +2,11 @@ package main
 
 import (
 	"context"
+	"errors"
 	"finance-chatbot/api/db"
+	"finance-chatbot/api/followups"
 	"finance-chatbot/api/handlers"
+	"finance-chatbot/api/jobs"
 	"finance-chatbot/api/kafka"
 	"finance-chatbot/api/logger"
 	"finance-chatbot/api/middleware"
@@ -51,6 +54,7 @@ func init() {
 		configuration.UseEnvironment(plaid.Sandbox)
 	}
 	handlers.PlaidClient = plaid.NewAPIClient(configuration)
+	middleware.PlaidClient = handlers.PlaidClient
 
 	// Initialize Stripe client
 	stripe.Key = os.Getenv("STRIPE_SECRET_KEY")
@@ -78,18 +82,39 @@ func main() {
 		logger.Get().Fatal("Failed to initialize Qdrant", zap.Error(err))
 	}
 	defer qdrant.CloseQdrantClient()
+	handlers.TransactionIndexer = qdrant.NewIndexer(qdrant.NewLLMEmbedder())
+
+	// A schema mismatch is logged rather than fatal so the server can still start and
+	// the collection can be migrated through the admin API
+	qdrantCtx, cancelQdrant := context.WithTimeout(context.Background(), 30*time.Second)
+	if err := qdrant.EnsureTransactionsCollection(qdrantCtx, handlers.TransactionIndexer.Dimension()); err != nil {
+		if errors.Is(err, qdrant.ErrSchemaMismatch) {
+			logger.Get().Error("Qdrant transactions collection needs migrating", zap.Error(err))
+		} else {
+			logger.Get().Fatal("Failed to set up Qdrant transactions collection", zap.Error(err))
+		}
+	}
+	cancelQdrant()
 
 	if err := kafka.InitProducer(); err != nil {
 		logger.Get().Fatal("Failed to initialize Kafka producer", zap.Error(err))
 	}
 	defer kafka.MessageProducer.Close()
 
+	// Suggest follow-up questions once each answer finishes streaming
+	kafka.OnGenerationComplete = followups.HandleGenerationComplete
+
 	err := kafka.StartKafkaConsumer()
 	if err != nil {
 		logger.Get().Fatal("Failed to start Kafka consumer", zap.Error(err))
 	}
 	defer kafka.WorkerPool.Stop()
 
+	// Permanently remove conversations once their restore window has passed
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	go jobs.RunConversationPurge(jobCtx, time.Hour)
+
 	// API routes
 	api := router.Group("/api")
 	{
@@ -100,15 +125,47 @@ func main() {
 		api.POST("/plaid/token/exchange", handlers.ExchangePublicToken)
 		api.POST("/plaid/transaction/list", handlers.GetTransactions)
 		api.POST("/plaid/transaction/save", handlers.ProvisionTransactionsJob)
+		api.POST("/transactions/search", handlers.HandleSearchTransactions)
 		api.POST("/plaid/account/list", handlers.GetItemsWithAccounts)
 		api.POST("/plaid/item/list", handlers.GetItems)
 		api.POST("/plaid/item/update", handlers.HandleSuccessfulPlaidItemUpdate)
+		api.POST("/plaid/item/remove", handlers.HandleRemovePlaidItem)
+		api.POST("/investments/holdings", handlers.HandleGetHoldings)
+		api.POST("/investments/allocation", handlers.HandleGetAllocation)
+		api.POST("/liabilities", handlers.HandleGetLiabilities)
+		api.POST("/plans/debt-payoff", handlers.HandleDebtPayoffPlan)
 		api.POST("/chat/conversation/new", handlers.HandleCreateNewConversation)
 		api.POST("/chat/conversation/list", handlers.HandleGetConversations)
 		api.POST("/chat/conversation/update", handlers.HandleUpdateConversation)
 		api.POST("/chat/conversation/delete", handlers.HandleDeleteConversation)
+		api.POST("/chat/conversation/restore", handlers.HandleRestoreConversation)
+		api.POST("/chat/conversation/archive", handlers.HandleArchiveConversation)
+		api.POST("/chat/conversation/pin", handlers.HandlePinConversation)
+		api.POST("/chat/conversation/export", handlers.HandleExportConversation)
+		api.POST("/chat/conversation/fork", handlers.HandleForkConversation)
+		api.POST("/chat/conversation/share", handlers.HandleCreateShare)
+		api.POST("/chat/conversation/share/list", handlers.HandleListShares)
+		api.POST("/chat/conversation/share/revoke", handlers.HandleRevokeShare)
+		api.POST("/chat/conversation/tag/add", handlers.HandleAddConversationTag)
+		api.POST("/chat/conversation/tag/remove", handlers.HandleRemoveConversationTag)
+		api.POST("/chat/conversation/folder/add", handlers.HandleAddConversationFolder)
+		api.POST("/chat/conversation/folder/remove", handlers.HandleRemoveConversationFolder)
+		api.POST("/chat/tag/create", handlers.HandleCreateTag)
+		api.POST("/chat/tag/list", handlers.HandleListTags)
+		api.POST("/chat/tag/update", handlers.HandleUpdateTag)
+		api.POST("/chat/tag/delete", handlers.HandleDeleteTag)
+		api.POST("/chat/folder/create", handlers.HandleCreateFolder)
+		api.POST("/chat/folder/list", handlers.HandleListFolders)
+		api.POST("/chat/folder/update", handlers.HandleUpdateFolder)
+		api.POST("/chat/folder/delete", handlers.HandleDeleteFolder)
 		api.POST("/chat/message/list", handlers.HandleGetMessagesByConversationID)
 		api.POST("/chat/message/send", handlers.HandleSendMessage)
+		api.POST("/chat/message/edit", handlers.HandleEditMessage)
+		api.POST("/chat/message/regenerate", handlers.HandleRegenerateMessage)
+		api.POST("/chat/message/cancel", handlers.HandleCancelGeneration)
+		api.POST("/chat/message/feedback", handlers.HandleMessageFeedback)
+		api.POST("/chat/search", handlers.HandleSearchMessages)
+		api.POST("/chat/starters", handlers.HandleGetStarters)
 		api.POST("/user-info/create", handlers.CreateUserInfo)
 		api.POST("/user-info/update", handlers.UpdateUserInfo)
 		api.POST("/user-info/delete", handlers.DeleteUserInfo)
@@ -118,6 +175,13 @@ func main() {
 		api.POST("/user/consent/update", handlers.HandleUpdateUserConsent)
 		api.POST("/stripe/session/create", handlers.HandleCreateStripeSession)
 		api.POST("/stripe/subscription/delete", handlers.HandleDeleteSubscription)
+
+		// Admin routes
+		admin := api.Group("/admin", middleware.AdminMiddleware)
+		admin.POST("/feedback/stats", handlers.HandleFeedbackStats)
+		admin.POST("/transactions/reindex", handlers.HandleReindexTransactions)
+		admin.POST("/transactions/migrate", handlers.HandleMigrateTransactionsCollection)
+		admin.POST("/webhooks/replay", handlers.HandleReplayWebhookEvent)
 	}
 
 	// Webhook routes
@@ -129,6 +193,7 @@ func main() {
 
 	// Public routes
 	router.GET("/sse/:conversationID", handlers.HandleSSE)
+	router.GET("/shared/:token", handlers.HandleGetSharedConversation)
 	router.GET("/metrics", func(c *gin.Context) {
 		kafka.WorkerPool.MetricsHandler(c.Writer, c.Request)
 	})
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"finance-chatbot/api/logger"
+	"finance-chatbot/api/models"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// AdminMiddleware only lets through users listed in ADMIN_USER_IDS. It must run
+// after AuthMiddleware.
+func AdminMiddleware(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		logger.Get().Error("user not authenticated")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	claims, ok := user.(*models.SupabaseClaims)
+	if !ok {
+		logger.Get().Error("invalid user claims")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid user claims"})
+		return
+	}
+
+	if !slices.Contains(strings.Split(os.Getenv("ADMIN_USER_IDS"), ","), claims.Sub) {
+		logger.Get().Warn("non-admin user attempted admin route",
+			zap.String("user_id", claims.Sub),
+			zap.String("path", c.Request.URL.Path))
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+		return
+	}
+
+	c.Next()
+}
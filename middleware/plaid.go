@@ -5,13 +5,17 @@ import (
 	"context"
 	"crypto/ecdsa"
 	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"finance-chatbot/api/logger"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
-	"os"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
@@ -19,7 +23,29 @@ import (
 	"go.uber.org/zap"
 )
 
-var cachedPlaidKey *plaid.JWKPublicKey
+const (
+	// plaidKeyTTL is how long a verification key is trusted before it is fetched again
+	plaidKeyTTL = 24 * time.Hour
+	// plaidWebhookMaxAge is the oldest a webhook's verification token may be, which
+	// keeps captured webhooks from being replayed
+	plaidWebhookMaxAge = 5 * time.Minute
+	// plaidClockSkew is how far ahead of our clock Plaid's iat may be before a token
+	// counts as issued in the future
+	plaidClockSkew = 5 * time.Second
+)
+
+// PlaidClient is the configured Plaid client; keys are fetched from its environment
+var PlaidClient *plaid.APIClient
+
+type cachedPlaidKey struct {
+	key       *ecdsa.PublicKey
+	expiresAt time.Time
+}
+
+var (
+	plaidKeys   = make(map[string]cachedPlaidKey)
+	plaidKeysMu sync.RWMutex
+)
 
 // PlaidWebhookVerifier ensures incoming Plaid webhooks are authentic
 func PlaidWebhookVerifier(c *gin.Context) {
@@ -64,29 +90,20 @@ func PlaidWebhookVerifier(c *gin.Context) {
 		return
 	}
 
-	// Fetch verification key if not cached
-	if cachedPlaidKey == nil || cachedPlaidKey.Kid != kid {
-		publicKey, fetchErr := fetchPlaidKey(kid)
-		if fetchErr != nil {
-			logger.Get().Error("failed to fetch public key", zap.Error(fetchErr))
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Failed to fetch verification key"})
-			return
-		}
-		cachedPlaidKey = &publicKey
-	}
-
-	// Construct ECDSA public key
-	pubKey, err := buildPublicKey(cachedPlaidKey)
+	pubKey, err := getPlaidKey(c.Request.Context(), kid)
 	if err != nil {
-		logger.Get().Error("failed to construct public key", zap.Error(err))
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid public key"})
+		logger.Get().Error("failed to get verification key",
+			zap.String("kid", kid),
+			zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Failed to fetch verification key"})
 		return
 	}
 
 	// Fully verify token using public key
-	_, err = jwt.Parse(tokenString, func(t *jwt.Token) (interface{}, error) {
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
 		return pubKey, nil
-	})
+	}, jwt.WithValidMethods([]string{"ES256"}), jwt.WithIssuedAt(), jwt.WithLeeway(plaidClockSkew))
 
 	if err != nil {
 		logger.Get().Error("JWT verification failed", zap.Error(err))
@@ -94,22 +111,74 @@ func PlaidWebhookVerifier(c *gin.Context) {
 		return
 	}
 
+	issuedAt, err := claims.GetIssuedAt()
+	if err != nil || issuedAt == nil || time.Since(issuedAt.Time) > plaidWebhookMaxAge {
+		logger.Get().Error("JWT too old or missing iat", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Expired verification token"})
+		return
+	}
+
+	// The token signs a hash of the body, which ties it to this request
+	bodyHash := sha256.Sum256(bodyBytes)
+	claimedHash, _ := claims["request_body_sha256"].(string)
+	if subtle.ConstantTimeCompare([]byte(claimedHash), []byte(hex.EncodeToString(bodyHash[:]))) != 1 {
+		logger.Get().Error("webhook body does not match request_body_sha256")
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Body hash mismatch"})
+		return
+	}
+
 	// JWT is valid, continue
 	c.Next()
 }
 
-// fetchPlaidKey retrieves the public key from Plaid
-func fetchPlaidKey(kid string) (plaid.JWKPublicKey, error) {
-	cfg := plaid.NewConfiguration()
-	cfg.AddDefaultHeader("PLAID-CLIENT-ID", os.Getenv("PLAID_CLIENT_ID"))
-	cfg.AddDefaultHeader("PLAID-SECRET", os.Getenv("PLAID_SECRET"))
-	cfg.UseEnvironment(plaid.Sandbox) // Change to Development or Production as needed
+// getPlaidKey returns the verification key for kid, fetching it from Plaid when it
+// isn't cached or its cache entry has expired
+func getPlaidKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	plaidKeysMu.RLock()
+	cached, ok := plaidKeys[kid]
+	plaidKeysMu.RUnlock()
 
-	client := plaid.NewAPIClient(cfg)
-	ctx := context.Background()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.key, nil
+	}
+
+	jwk, err := fetchPlaidKey(ctx, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	// Plaid sets expired_at once a key has been rotated out
+	expiresAt := time.Now().Add(plaidKeyTTL)
+	if expiredAt := jwk.GetExpiredAt(); expiredAt != 0 {
+		keyExpiry := time.Unix(int64(expiredAt), 0)
+		if !time.Now().Before(keyExpiry) {
+			return nil, fmt.Errorf("key %s expired at %s", kid, keyExpiry)
+		}
+		if keyExpiry.Before(expiresAt) {
+			expiresAt = keyExpiry
+		}
+	}
+
+	key, err := buildPublicKey(&jwk)
+	if err != nil {
+		return nil, err
+	}
+
+	plaidKeysMu.Lock()
+	plaidKeys[kid] = cachedPlaidKey{key: key, expiresAt: expiresAt}
+	plaidKeysMu.Unlock()
+
+	return key, nil
+}
+
+// fetchPlaidKey retrieves the public key from Plaid
+func fetchPlaidKey(ctx context.Context, kid string) (plaid.JWKPublicKey, error) {
+	if PlaidClient == nil {
+		return plaid.JWKPublicKey{}, fmt.Errorf("PlaidClient is not initialized")
+	}
 
 	req := plaid.NewWebhookVerificationKeyGetRequest(kid)
-	resp, _, err := client.PlaidApi.WebhookVerificationKeyGet(ctx).
+	resp, _, err := PlaidClient.PlaidApi.WebhookVerificationKeyGet(ctx).
 		WebhookVerificationKeyGetRequest(*req).Execute()
 
 	if err != nil {
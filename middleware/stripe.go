@@ -10,7 +10,11 @@ import (
 	"github.com/stripe/stripe-go/v82/webhook"
 )
 
-const StripeEventKey = "stripe_event"
+const (
+	StripeEventKey = "stripe_event"
+	// StripePayloadKey holds the verified request body so it can be stored as received
+	StripePayloadKey = "stripe_payload"
+)
 
 func StripeWebhookVerifier(c *gin.Context) {
 	if c.Request.Method != "POST" {
@@ -32,5 +36,6 @@ func StripeWebhookVerifier(c *gin.Context) {
 	}
 
 	c.Set(StripeEventKey, event)
+	c.Set(StripePayloadKey, b)
 	c.Next()
 }
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/v2/bson"
 )
 
 type Context struct {
@@ -15,15 +16,44 @@ type Context struct {
 	SavingsGoal        float64   `json:"savings_goal" bson:"savings_goal"`
 	AdditionalExpenses []Expense `json:"additional_monthly_expenses" bson:"additional_monthly_expenses"`
 	Accounts           []Account `json:"accounts" bson:"accounts"`
+	// Investments is nil when the user has no holdings
+	Investments *InvestmentSummary `json:"investments,omitempty" bson:"investments,omitempty"`
+	Debts       []Debt             `json:"debts,omitempty" bson:"debts,omitempty"`
 }
 
+// SenderUser marks messages written by the user; every other sender is the assistant
+const SenderUser = "UserMessage"
+
 type Message struct {
-	ConversationID string `json:"conversation_id" bson:"conversation_id"`
-	UserID         string `json:"user_id" bson:"user_id"`
-	Text           string `json:"message" bson:"message"`
-	Sender         string `json:"sender" bson:"sender"`
-	Error          bool   `json:"error" bson:"error"`
-	Timestamp      int64  `json:"timestamp" bson:"timestamp"`
+	ID             bson.ObjectID `json:"id" bson:"_id,omitempty"`
+	ConversationID string        `json:"conversation_id" bson:"conversation_id"`
+	UserID         string        `json:"user_id" bson:"user_id"`
+	Text           string        `json:"message" bson:"message"`
+	Sender         string        `json:"sender" bson:"sender"`
+	Error          bool          `json:"error" bson:"error"`
+	Timestamp      int64         `json:"timestamp" bson:"timestamp"` // Unix milliseconds
+	Sequence       int64         `json:"sequence" bson:"sequence"`
+
+	// Superseded messages were replaced by an edit or regeneration and are kept for audit
+	Superseded bool `json:"superseded" bson:"superseded"`
+	// Regenerate tells the AI service to answer again rather than continue the thread
+	Regenerate bool `json:"regenerate,omitempty" bson:"-"`
+	// GenerationID identifies one request for an answer; the AI service echoes it on
+	// every chunk of the reply so the answer can be cancelled
+	GenerationID string `json:"generation_id,omitempty" bson:"generation_id,omitempty"`
+	// ReplySequence is reserved for the answer when a user message is sent to the AI
+	// service, which must store its reply under that sequence so replies are ordered
+	// and paged with the rest of the conversation
+	ReplySequence int64 `json:"reply_sequence,omitempty" bson:"-"`
+	// Metadata is attached by the AI service to assistant messages
+	Metadata *ResponseMetadata `json:"metadata,omitempty" bson:"metadata,omitempty"`
+	// Suggestions are follow-up prompts generated after an assistant message finishes
+	Suggestions []string `json:"suggestions,omitempty" bson:"suggestions,omitempty"`
+}
+
+type ResponseMetadata struct {
+	ModelVersion  string `json:"model_version" bson:"model_version"`
+	PromptVersion string `json:"prompt_version,omitempty" bson:"prompt_version,omitempty"`
 }
 
 type AIResponse struct {
@@ -31,9 +61,43 @@ type AIResponse struct {
 	LastMessage bool `json:"last_message" bson:"last_message"`
 }
 
+type MessageSearchResult struct {
+	ConversationID string  `json:"conversation_id" bson:"conversation_id"`
+	Title          string  `json:"title" bson:"-"`
+	Sender         string  `json:"sender" bson:"sender"`
+	Text           string  `json:"-" bson:"message"`
+	Snippet        string  `json:"snippet" bson:"-"`
+	Timestamp      int64   `json:"timestamp" bson:"timestamp"`
+	Score          float64 `json:"score" bson:"score"`
+}
+
 type Conversation struct {
-	ID        uuid.UUID `json:"id"`
-	UserID    string    `json:"user_id"`
-	CreatedAt time.Time `json:"created_at"`
-	Title     string    `json:"title"`
+	ID            uuid.UUID `json:"id"`
+	UserID        string    `json:"user_id"`
+	CreatedAt     time.Time `json:"created_at"`
+	Title         string    `json:"title"`
+	LastMessageAt time.Time `json:"last_message_at"`
+
+	// Set when the conversation was forked from another conversation
+	ParentConversationID *uuid.UUID `json:"parent_conversation_id"`
+	ForkedFromSequence   *int64     `json:"forked_from_sequence"`
+
+	Archived  bool       `json:"archived"`
+	Pinned    bool       `json:"pinned"`
+	DeletedAt *time.Time `json:"deleted_at"`
+
+	TagIDs    []string `json:"tag_ids"`
+	FolderIDs []string `json:"folder_ids"`
+}
+
+// ConversationExportVersion is bumped whenever ConversationExport changes shape
+const ConversationExportVersion = 1
+
+// ConversationExport is the re-importable snapshot of a conversation
+type ConversationExport struct {
+	Version      int          `json:"version"`
+	ExportedAt   time.Time    `json:"exported_at"`
+	Conversation Conversation `json:"conversation"`
+	Context      *Context     `json:"context"`
+	Messages     []Message    `json:"messages"`
 }
@@ -0,0 +1,45 @@
+package models
+
+import "go.mongodb.org/mongo-driver/v2/bson"
+
+type FeedbackRating string
+
+const (
+	FeedbackRatingUp   FeedbackRating = "up"
+	FeedbackRatingDown FeedbackRating = "down"
+)
+
+// FeedbackReasons are the reason codes a user can attach to feedback
+var FeedbackReasons = map[string]bool{
+	"inaccurate":    true,
+	"wrong_numbers": true,
+	"incomplete":    true,
+	"unhelpful":     true,
+	"too_long":      true,
+	"off_topic":     true,
+	"unsafe":        true,
+	"other":         true,
+}
+
+type MessageFeedback struct {
+	ID             bson.ObjectID  `json:"id" bson:"_id,omitempty"`
+	MessageID      bson.ObjectID  `json:"message_id" bson:"message_id"`
+	ConversationID string         `json:"conversation_id" bson:"conversation_id"`
+	UserID         string         `json:"user_id" bson:"user_id"`
+	Rating         FeedbackRating `json:"rating" bson:"rating"`
+	Reasons        []string       `json:"reasons" bson:"reasons"`
+	Comment        string         `json:"comment" bson:"comment"`
+	ModelVersion   string         `json:"model_version" bson:"model_version"`
+	CreatedAt      int64          `json:"created_at" bson:"created_at"` // Unix milliseconds
+	UpdatedAt      int64          `json:"updated_at" bson:"updated_at"` // Unix milliseconds
+}
+
+// FeedbackStats summarizes the feedback left on one day for one model version
+type FeedbackStats struct {
+	Day          string  `json:"day" bson:"day"`
+	ModelVersion string  `json:"model_version" bson:"model_version"`
+	Total        int     `json:"total" bson:"total"`
+	Up           int     `json:"up" bson:"up"`
+	Down         int     `json:"down" bson:"down"`
+	PositiveRate float64 `json:"positive_rate" bson:"-"`
+}
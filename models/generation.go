@@ -0,0 +1,10 @@
+package models
+
+// CancelGeneration is published on the cancel topic when a user stops an answer
+// that is still being generated
+type CancelGeneration struct {
+	ConversationID string `json:"conversation_id"`
+	GenerationID   string `json:"generation_id"`
+	UserID         string `json:"user_id"`
+	CancelledAt    int64  `json:"cancelled_at"` // Unix milliseconds
+}
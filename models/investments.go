@@ -0,0 +1,83 @@
+package models
+
+// Holding is a position in one security held in one investment account
+type Holding struct {
+	AccountID        string   `json:"account_id" bson:"account_id"`
+	SecurityID       string   `json:"security_id" bson:"security_id"`
+	Quantity         float64  `json:"quantity" bson:"quantity"`
+	InstitutionPrice float64  `json:"institution_price" bson:"institution_price"`
+	InstitutionValue float64  `json:"institution_value" bson:"institution_value"`
+	CostBasis        *float64 `json:"cost_basis" bson:"cost_basis"`
+	IsoCurrencyCode  string   `json:"iso_currency_code" bson:"iso_currency_code"`
+}
+
+// Security is a stock, fund, bond or other instrument referenced by holdings. Type is
+// Plaid's security type, such as equity, etf, mutual fund or fixed income.
+type Security struct {
+	SecurityID       string   `json:"security_id" bson:"security_id"`
+	Name             string   `json:"name" bson:"name"`
+	TickerSymbol     string   `json:"ticker_symbol" bson:"ticker_symbol"`
+	Type             string   `json:"type" bson:"type"`
+	Sector           string   `json:"sector" bson:"sector"`
+	IsCashEquivalent bool     `json:"is_cash_equivalent" bson:"is_cash_equivalent"`
+	ClosePrice       *float64 `json:"close_price" bson:"close_price"`
+	IsoCurrencyCode  string   `json:"iso_currency_code" bson:"iso_currency_code"`
+}
+
+// InvestmentSnapshot is a Plaid item's holdings as of UpdatedAt. Items without
+// investment accounts are stored with no holdings so they aren't fetched again.
+type InvestmentSnapshot struct {
+	UserID     string     `json:"user_id" bson:"user_id"`
+	ItemID     string     `json:"item_id" bson:"item_id"`
+	Holdings   []Holding  `json:"holdings" bson:"holdings"`
+	Securities []Security `json:"securities" bson:"securities"`
+	UpdatedAt  int64      `json:"updated_at" bson:"updated_at"`
+}
+
+// Position is a holding joined with its security. Weight is the position's share of
+// the portfolio's value.
+type Position struct {
+	AccountID    string   `json:"account_id,omitempty" bson:"account_id,omitempty"`
+	SecurityID   string   `json:"security_id" bson:"security_id"`
+	Name         string   `json:"name" bson:"name"`
+	TickerSymbol string   `json:"ticker_symbol" bson:"ticker_symbol"`
+	AssetClass   string   `json:"asset_class" bson:"asset_class"`
+	Quantity     float64  `json:"quantity" bson:"quantity"`
+	Price        float64  `json:"price" bson:"price"`
+	Value        float64  `json:"value" bson:"value"`
+	CostBasis    *float64 `json:"cost_basis,omitempty" bson:"cost_basis,omitempty"`
+	Weight       float64  `json:"weight" bson:"weight"`
+}
+
+type AssetClassAllocation struct {
+	AssetClass string  `json:"asset_class" bson:"asset_class"`
+	Value      float64 `json:"value" bson:"value"`
+	Weight     float64 `json:"weight" bson:"weight"`
+}
+
+// Concentration describes how much of the non-cash portfolio sits in the largest
+// securities. HHI is the Herfindahl index of security weights: near 0 for a widely
+// spread portfolio and 1 for a single security.
+type Concentration struct {
+	TopPositions  []Position `json:"top_positions" bson:"top_positions"`
+	LargestWeight float64    `json:"largest_weight" bson:"largest_weight"`
+	TopFiveWeight float64    `json:"top_five_weight" bson:"top_five_weight"`
+	HHI           float64    `json:"hhi" bson:"hhi"`
+	Concentrated  bool       `json:"concentrated" bson:"concentrated"`
+}
+
+type Allocation struct {
+	TotalValue    float64                `json:"total_value" bson:"total_value"`
+	AssetClasses  []AssetClassAllocation `json:"asset_classes" bson:"asset_classes"`
+	Concentration Concentration          `json:"concentration" bson:"concentration"`
+}
+
+// InvestmentSummary is the compact view of a user's holdings kept in a conversation
+// Context
+type InvestmentSummary struct {
+	TotalValue   float64                `json:"total_value" bson:"total_value"`
+	AssetClasses []AssetClassAllocation `json:"asset_classes" bson:"asset_classes"`
+	TopHoldings  []Position             `json:"top_holdings" bson:"top_holdings"`
+	Concentrated bool                   `json:"concentrated" bson:"concentrated"`
+	UpdatedAt    int64                  `json:"updated_at" bson:"updated_at"`
+}
@@ -0,0 +1,34 @@
+package models
+
+// Debt types
+const (
+	DebtTypeCreditCard  = "credit_card"
+	DebtTypeStudentLoan = "student_loan"
+	DebtTypeMortgage    = "mortgage"
+)
+
+// Debt is a credit card, student loan or mortgage from Plaid Liabilities. APR is an
+// annual percentage such as 24.99, and fields Plaid doesn't report are nil or empty.
+// StatementBalance is only reported for credit cards and student loans.
+type Debt struct {
+	AccountID          string   `json:"account_id" bson:"account_id"`
+	ItemID             string   `json:"item_id" bson:"item_id"`
+	Name               string   `json:"name" bson:"name"`
+	Type               string   `json:"type" bson:"type"`
+	Balance            float64  `json:"balance" bson:"balance"`
+	APR                *float64 `json:"apr" bson:"apr"`
+	MinimumPayment     *float64 `json:"minimum_payment" bson:"minimum_payment"`
+	NextPaymentDueDate string   `json:"next_payment_due_date" bson:"next_payment_due_date"`
+	StatementBalance   *float64 `json:"statement_balance,omitempty" bson:"statement_balance,omitempty"`
+	IsOverdue          bool     `json:"is_overdue" bson:"is_overdue"`
+	IsoCurrencyCode    string   `json:"iso_currency_code" bson:"iso_currency_code"`
+}
+
+// LiabilitiesSnapshot is a Plaid item's debts as of UpdatedAt. Items without
+// liability accounts are stored with no debts so they aren't fetched again.
+type LiabilitiesSnapshot struct {
+	UserID    string `json:"user_id" bson:"user_id"`
+	ItemID    string `json:"item_id" bson:"item_id"`
+	Debts     []Debt `json:"debts" bson:"debts"`
+	UpdatedAt int64  `json:"updated_at" bson:"updated_at"`
+}
@@ -3,10 +3,12 @@ package models
 import (
 	"database/sql"
 	"fmt"
+	"slices"
 )
 
 type Transaction struct {
 	TransactionID string  `json:"transaction_id"`
+	AccountID     string  `json:"account_id"`
 	Date          string  `json:"date"`
 	Amount        float64 `json:"amount"`
 	Name          string  `json:"name"`
@@ -17,6 +19,7 @@ type Transaction struct {
 
 type Account struct {
 	AccountID    string   `json:"account_id" bson:"account_id"`
+	ItemID       string   `json:"item_id,omitempty" bson:"item_id,omitempty"`
 	Name         string   `json:"name" bson:"name"`
 	OfficialName string   `json:"official_name" bson:"official_name"`
 	Type         string   `json:"type" bson:"type"`
@@ -32,23 +35,45 @@ type Balances struct {
 	Limit           *float64 `json:"limit" bson:"limit"`
 }
 
+// GenericPlaidWebhook holds the fields of the Plaid webhooks we handle. AccountID is
+// set on USER_ACCOUNT_REVOKED, Reason on PENDING_DISCONNECT, NewWebhookURL on
+// WEBHOOK_UPDATE_ACKNOWLEDGED and RemovedTransactions on TRANSACTIONS_REMOVED.
 type GenericPlaidWebhook struct {
-	WebhookType string `json:"webhook_type"`
-	WebhookCode string `json:"webhook_code"`
-	ItemID      string `json:"item_id"`
+	WebhookType   string      `json:"webhook_type"`
+	WebhookCode   string      `json:"webhook_code"`
+	ItemID        string      `json:"item_id"`
+	Error         *PlaidError `json:"error"`
+	AccountID     string      `json:"account_id"`
+	Reason        string      `json:"reason"`
+	NewWebhookURL string      `json:"new_webhook_url"`
+
+	RemovedTransactions []string `json:"removed_transactions"`
 }
 
+// PlaidItem is a linked bank. ErrorCode is the Plaid error that put the item in its
+// current status, and NewAccountsAvailable is set when the user can share more
+// accounts through update mode.
 type PlaidItem struct {
-	ID           string       `json:"id"`
-	UserID       string       `json:"user_id"`
-	AccessToken  string       `json:"access_token"`
-	ItemID       string       `json:"item_id"`
-	Status       string       `json:"status"`
-	CreatedAt    sql.NullTime `json:"created_at"`
-	UpdatedAt    sql.NullTime `json:"updated_at"`
-	LastSyncedAt sql.NullTime `json:"last_synced_at"`
-	SyncStatus   SyncStatus   `json:"sync_status"`
-	Cursor       *string      `json:"cursor"`
+	ID                   string       `json:"id"`
+	UserID               string       `json:"user_id"`
+	AccessToken          string       `json:"access_token"`
+	ItemID               string       `json:"item_id"`
+	Status               ItemStatus   `json:"status"`
+	ErrorCode            *string      `json:"error_code"`
+	NewAccountsAvailable bool         `json:"new_accounts_available"`
+	Products             []string     `json:"products"`
+	AccountIDs           []string     `json:"-"`
+	CreatedAt            sql.NullTime `json:"created_at"`
+	UpdatedAt            sql.NullTime `json:"updated_at"`
+	LastSyncedAt         sql.NullTime `json:"last_synced_at"`
+	SyncStatus           SyncStatus   `json:"sync_status"`
+	Cursor               *string      `json:"cursor"`
+}
+
+// HasProduct reports whether product is enabled on the item. Products is nil until
+// the item has been looked up, in which case it reports false.
+func (item *PlaidItem) HasProduct(product string) bool {
+	return slices.Contains(item.Products, product)
 }
 
 type PlaidError struct {
@@ -82,6 +107,31 @@ const (
 type ItemStatus string
 
 const (
-	ItemStatusHealthy ItemStatus = "HEALTHY"
-	ItemStatusError   ItemStatus = "ERROR"
+	ItemStatusHealthy           ItemStatus = "healthy"
+	ItemStatusLoginRequired     ItemStatus = "login_required"
+	ItemStatusPendingExpiration ItemStatus = "pending_expiration"
+	ItemStatusPendingDisconnect ItemStatus = "pending_disconnect"
+	ItemStatusRevoked           ItemStatus = "revoked"
+	ItemStatusError             ItemStatus = "error"
 )
+
+// CanTransitionTo reports whether an item may move from s to next. A revoked item
+// can't be repaired through update mode, the user has to link the bank again, so
+// revoked is final. Every other status can move to any status.
+func (s ItemStatus) CanTransitionTo(next ItemStatus) bool {
+	switch next {
+	case ItemStatusHealthy, ItemStatusLoginRequired, ItemStatusPendingExpiration,
+		ItemStatusPendingDisconnect, ItemStatusRevoked, ItemStatusError:
+	default:
+		return false
+	}
+	return s != ItemStatusRevoked || next == ItemStatusRevoked
+}
+
+// TransactionSearchResult is a transaction matched by semantic search. Text is the
+// document that was embedded for the transaction.
+type TransactionSearchResult struct {
+	Transaction
+	Text  string  `json:"text"`
+	Score float32 `json:"score"`
+}
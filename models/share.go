@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type ConversationShare struct {
+	ID             uuid.UUID  `json:"id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	UserID         string     `json:"user_id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      time.Time  `json:"expires_at"`
+	RevokedAt      *time.Time `json:"revoked_at"`
+}
+
+// SharedConversation is the public, read-only view served for a share link
+type SharedConversation struct {
+	Title     string          `json:"title"`
+	CreatedAt time.Time       `json:"created_at"`
+	Context   *SharedContext  `json:"context"`
+	Messages  []SharedMessage `json:"messages"`
+}
+
+// SharedContext is a Context with the user's name, income, identifiers, account masks
+// and balances removed
+type SharedContext struct {
+	SavingsGoal        float64         `json:"savings_goal"`
+	AdditionalExpenses []Expense       `json:"additional_monthly_expenses"`
+	Accounts           []SharedAccount `json:"accounts"`
+}
+
+type SharedAccount struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Subtype string `json:"subtype"`
+}
+
+type SharedMessage struct {
+	Text      string `json:"message"`
+	Sender    string `json:"sender"`
+	Timestamp int64  `json:"timestamp"`
+}
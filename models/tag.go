@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Label is a user-defined tag or folder for conversations. A conversation can carry
+// any number of tags and be filed in more than one folder.
+type Label struct {
+	ID        uuid.UUID `json:"id"`
+	UserID    string    `json:"user_id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type (
+	Tag    = Label
+	Folder = Label
+)
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookSourceStripe = "stripe"
+	WebhookSourcePlaid  = "plaid"
+)
+
+type WebhookEventStatus string
+
+const (
+	WebhookEventReceived   WebhookEventStatus = "received"
+	WebhookEventProcessing WebhookEventStatus = "processing"
+	WebhookEventProcessed  WebhookEventStatus = "processed"
+	WebhookEventFailed     WebhookEventStatus = "failed"
+)
+
+// WebhookEvent is a received webhook. EventID is Stripe's event ID, or a hash of the
+// body for Plaid, and Payload is the body exactly as it arrived.
+type WebhookEvent struct {
+	ID          uuid.UUID          `json:"id"`
+	Source      string             `json:"source"`
+	EventID     string             `json:"event_id"`
+	EventType   string             `json:"event_type"`
+	Payload     []byte             `json:"-"`
+	Status      WebhookEventStatus `json:"status"`
+	Error       *string            `json:"error"`
+	Attempts    int                `json:"attempts"`
+	ReceivedAt  time.Time          `json:"received_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+	ProcessedAt *time.Time         `json:"processed_at"`
+}
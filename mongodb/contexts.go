@@ -4,6 +4,9 @@ import (
 	"context"
 	"finance-chatbot/api/models"
 	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 )
 
 func CreateConversationContext(ctx context.Context, item *models.Context) error {
@@ -15,6 +18,21 @@ func CreateConversationContext(ctx context.Context, item *models.Context) error
 	return nil
 }
 
+func GetConversationContext(ctx context.Context, conversationID string) (*models.Context, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(ContextCollection)
+
+	var item models.Context
+	err := collection.FindOne(ctx, bson.M{"conversation_id": conversationID}).Decode(&item)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching mongo item: %v", err)
+	}
+
+	return &item, nil
+}
+
 func UpdateConversationContext(ctx context.Context, conversationID string, updates map[string]any) error {
 	collection := MongoClient.Database(MongoDatabase).Collection(ContextCollection)
 
@@ -52,3 +70,31 @@ func DeleteContextsByUserID(ctx context.Context, userID string) error {
 
 	return nil
 }
+
+// RemoveAccountsFromContexts drops accounts from every context the user has. Accounts
+// are matched on itemID, or on accountIDs for contexts stored before accounts recorded
+// their item. An empty itemID matches on accountIDs alone.
+func RemoveAccountsFromContexts(ctx context.Context, userID string, itemID string, accountIDs []string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(ContextCollection)
+
+	match := bson.A{}
+	if itemID != "" {
+		match = append(match, bson.M{"item_id": itemID})
+	}
+	if len(accountIDs) > 0 {
+		match = append(match, bson.M{"account_id": bson.M{"$in": accountIDs}})
+	}
+	if len(match) == 0 {
+		return nil
+	}
+
+	_, err := collection.UpdateMany(
+		ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$pull": bson.M{"accounts": bson.M{"$or": match}}},
+	)
+	if err != nil {
+		return fmt.Errorf("error removing accounts from contexts for user_id %s: %v", userID, err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package mongodb
+
+import (
+	"context"
+	"finance-chatbot/api/models"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// UpsertMessageFeedback stores a user's feedback on a message, replacing any feedback
+// they left on it before
+func UpsertMessageFeedback(ctx context.Context, feedback *models.MessageFeedback) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(FeedbackCollection)
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{
+			"message_id": feedback.MessageID,
+			"user_id":    feedback.UserID,
+		},
+		bson.M{
+			"$set": bson.M{
+				"conversation_id": feedback.ConversationID,
+				"rating":          feedback.Rating,
+				"reasons":         feedback.Reasons,
+				"comment":         feedback.Comment,
+				"model_version":   feedback.ModelVersion,
+				"updated_at":      feedback.UpdatedAt,
+			},
+			"$setOnInsert": bson.M{
+				"created_at": feedback.CreatedAt,
+			},
+		},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("error saving feedback: %v", err)
+	}
+	return nil
+}
+
+// AggregateFeedback counts feedback per UTC day and model version between from and to
+func AggregateFeedback(ctx context.Context, from time.Time, to time.Time) ([]models.FeedbackStats, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(FeedbackCollection)
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"updated_at": bson.M{"$gte": from.UnixMilli(), "$lt": to.UnixMilli()},
+		}},
+		bson.M{"$group": bson.M{
+			"_id": bson.M{
+				"day": bson.M{"$dateToString": bson.M{
+					"format": "%Y-%m-%d",
+					"date":   bson.M{"$toDate": "$updated_at"},
+				}},
+				"model_version": "$model_version",
+			},
+			"total": bson.M{"$sum": 1},
+			"up": bson.M{"$sum": bson.M{
+				"$cond": bson.A{bson.M{"$eq": bson.A{"$rating", models.FeedbackRatingUp}}, 1, 0},
+			}},
+			"down": bson.M{"$sum": bson.M{
+				"$cond": bson.A{bson.M{"$eq": bson.A{"$rating", models.FeedbackRatingDown}}, 1, 0},
+			}},
+		}},
+		bson.M{"$project": bson.M{
+			"_id":           0,
+			"day":           "$_id.day",
+			"model_version": "$_id.model_version",
+			"total":         1,
+			"up":            1,
+			"down":          1,
+		}},
+		bson.M{"$sort": bson.D{{Key: "day", Value: 1}, {Key: "model_version", Value: 1}}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("error aggregating feedback: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	stats := []models.FeedbackStats{}
+	if err := cursor.All(ctx, &stats); err != nil {
+		return nil, fmt.Errorf("error decoding feedback stats: %v", err)
+	}
+
+	for i := range stats {
+		if stats[i].Total > 0 {
+			stats[i].PositiveRate = float64(stats[i].Up) / float64(stats[i].Total)
+		}
+	}
+
+	return stats, nil
+}
+
+func DeleteFeedbackByConversationID(ctx context.Context, conversationID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(FeedbackCollection)
+	_, err := collection.DeleteMany(ctx, bson.M{"conversation_id": conversationID})
+	if err != nil {
+		return fmt.Errorf("error deleting feedback: %v", err)
+	}
+	return nil
+}
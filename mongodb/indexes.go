@@ -0,0 +1,77 @@
+package mongodb
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// EnsureIndexes creates the indexes the API relies on. Creating an index that
+// already exists with the same definition is a no-op.
+func EnsureIndexes(ctx context.Context) error {
+	messages := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+	_, err := messages.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "message", Value: "text"}},
+			Options: options.Index().SetName("message_text"),
+		},
+		{
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "timestamp", Value: 1}},
+			Options: options.Index().SetName("conversation_timestamp"),
+		},
+		{
+			Keys:    bson.D{{Key: "conversation_id", Value: 1}, {Key: "sequence", Value: 1}},
+			Options: options.Index().SetName("conversation_sequence"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating message indexes: %v", err)
+	}
+
+	counters := MongoClient.Database(MongoDatabase).Collection(MessageCounterCollection)
+	_, err = counters.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "conversation_id", Value: 1}},
+		Options: options.Index().SetName("conversation_id").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating message counter index: %v", err)
+	}
+
+	feedback := MongoClient.Database(MongoDatabase).Collection(FeedbackCollection)
+	_, err = feedback.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "message_id", Value: 1}, {Key: "user_id", Value: 1}},
+			Options: options.Index().SetName("message_user").SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "updated_at", Value: 1}},
+			Options: options.Index().SetName("updated_at"),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("error creating feedback indexes: %v", err)
+	}
+
+	investments := MongoClient.Database(MongoDatabase).Collection(InvestmentCollection)
+	_, err = investments.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "item_id", Value: 1}},
+		Options: options.Index().SetName("user_item").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating investment index: %v", err)
+	}
+
+	liabilities := MongoClient.Database(MongoDatabase).Collection(LiabilitiesCollection)
+	_, err = liabilities.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "item_id", Value: 1}},
+		Options: options.Index().SetName("user_item").SetUnique(true),
+	})
+	if err != nil {
+		return fmt.Errorf("error creating liabilities index: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,59 @@
+package mongodb
+
+import (
+	"context"
+	"finance-chatbot/api/models"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ReplaceInvestmentSnapshot stores an item's holdings, replacing any earlier snapshot
+func ReplaceInvestmentSnapshot(ctx context.Context, snapshot *models.InvestmentSnapshot) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(InvestmentCollection)
+
+	filter := bson.M{"user_id": snapshot.UserID, "item_id": snapshot.ItemID}
+	_, err := collection.ReplaceOne(ctx, filter, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error replacing investment snapshot for item_id %s: %v", snapshot.ItemID, err)
+	}
+	return nil
+}
+
+// GetInvestmentSnapshots returns the stored holdings of each of the user's items
+func GetInvestmentSnapshots(ctx context.Context, userID string) ([]*models.InvestmentSnapshot, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(InvestmentCollection)
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching investment snapshots: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := []*models.InvestmentSnapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("error decoding investment snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+func DeleteInvestmentSnapshot(ctx context.Context, itemID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(InvestmentCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"item_id": itemID})
+	if err != nil {
+		return fmt.Errorf("error deleting investment snapshot for item_id %s: %v", itemID, err)
+	}
+	return nil
+}
+
+func DeleteInvestmentsByUserID(ctx context.Context, userID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(InvestmentCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("error deleting investment snapshots for user_id %s: %v", userID, err)
+	}
+	return nil
+}
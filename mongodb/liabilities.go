@@ -0,0 +1,59 @@
+package mongodb
+
+import (
+	"context"
+	"finance-chatbot/api/models"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+)
+
+// ReplaceLiabilitiesSnapshot stores an item's debts, replacing any earlier snapshot
+func ReplaceLiabilitiesSnapshot(ctx context.Context, snapshot *models.LiabilitiesSnapshot) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(LiabilitiesCollection)
+
+	filter := bson.M{"user_id": snapshot.UserID, "item_id": snapshot.ItemID}
+	_, err := collection.ReplaceOne(ctx, filter, snapshot, options.Replace().SetUpsert(true))
+	if err != nil {
+		return fmt.Errorf("error replacing liabilities snapshot for item_id %s: %v", snapshot.ItemID, err)
+	}
+	return nil
+}
+
+// GetLiabilitiesSnapshots returns the stored debts of each of the user's items
+func GetLiabilitiesSnapshots(ctx context.Context, userID string) ([]*models.LiabilitiesSnapshot, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(LiabilitiesCollection)
+
+	cursor, err := collection.Find(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return nil, fmt.Errorf("error fetching liabilities snapshots: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	snapshots := []*models.LiabilitiesSnapshot{}
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, fmt.Errorf("error decoding liabilities snapshots: %v", err)
+	}
+	return snapshots, nil
+}
+
+func DeleteLiabilitiesSnapshot(ctx context.Context, itemID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(LiabilitiesCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"item_id": itemID})
+	if err != nil {
+		return fmt.Errorf("error deleting liabilities snapshot for item_id %s: %v", itemID, err)
+	}
+	return nil
+}
+
+func DeleteLiabilitiesByUserID(ctx context.Context, userID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(LiabilitiesCollection)
+
+	_, err := collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return fmt.Errorf("error deleting liabilities snapshots for user_id %s: %v", userID, err)
+	}
+	return nil
+}
@@ -1,37 +1,172 @@
 package mongodb
 
 import (
+	"cmp"
 	"context"
 	"finance-chatbot/api/models"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
 )
 
+// MessageListParams pages through a conversation's messages by sequence number.
+// Before and After are exclusive cursors; a zero Limit returns every matching message.
+type MessageListParams struct {
+	Before            int64
+	After             int64
+	Limit             int64
+	IncludeSuperseded bool
+}
+
+// CreateMessage stores a message, assigning the conversation's next sequence number
+// when the message does not already have one
 func CreateMessage(ctx context.Context, message *models.Message) error {
+	if message.Sequence == 0 {
+		sequence, err := NextMessageSequence(ctx, message.ConversationID)
+		if err != nil {
+			return err
+		}
+		message.Sequence = sequence
+	}
+
 	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
-	_, err := collection.InsertOne(ctx, message)
+	result, err := collection.InsertOne(ctx, message)
 	if err != nil {
 		return fmt.Errorf("error creating mongo item: %v", err)
 	}
+	if id, ok := result.InsertedID.(bson.ObjectID); ok {
+		message.ID = id
+	}
 	return nil
 }
 
-func GetMessagesByConversationID(ctx context.Context, userID string, conversationID string) ([]models.Message, error) {
+// NextMessageSequence atomically reserves the next sequence number for a conversation
+func NextMessageSequence(ctx context.Context, conversationID string) (int64, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCounterCollection)
+
+	var counter struct {
+		Sequence int64 `bson:"sequence"`
+	}
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"conversation_id": conversationID},
+		bson.M{"$inc": bson.M{"sequence": 1}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("error reserving message sequence: %v", err)
+	}
+
+	return counter.Sequence, nil
+}
+
+// GetMessagesByConversationID returns a page of messages in conversation order and
+// whether more sequenced messages exist beyond the page in the direction being paged.
+// Cursors and the limit apply to sequenced messages. Replies the AI service saved
+// without a sequence are placed by timestamp: each one belongs to the page of the
+// first sequenced message written after it, or to the newest page when there is none.
+// Ownership of the conversation must be checked by the caller.
+func GetMessagesByConversationID(ctx context.Context, conversationID string, params MessageListParams) ([]models.Message, bool, error) {
 	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
-	filter := bson.M{
+	base := bson.M{
 		"conversation_id": conversationID,
 	}
-	opts := options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}})
+	if !params.IncludeSuperseded {
+		base["superseded"] = bson.M{"$ne": true}
+	}
+
+	sequenceRange := bson.M{"$gt": int64(0)}
+	if params.Before > 0 {
+		sequenceRange["$lt"] = params.Before
+	}
+	if params.After > 0 {
+		sequenceRange["$gt"] = params.After
+	}
+
+	// Without an after cursor we page backwards from the newest message, so the
+	// query runs in descending order and the page is reversed before returning
+	descending := params.After == 0 && params.Limit > 0
+	direction := 1
+	if descending {
+		direction = -1
+	}
+
+	opts := options.Find().SetSort(bson.D{
+		{Key: "sequence", Value: direction},
+		{Key: "_id", Value: direction},
+	})
+	if params.Limit > 0 {
+		opts.SetLimit(params.Limit + 1)
+	}
+
+	sequenced, err := findMessages(ctx, collection, withConditions(base, bson.M{"sequence": sequenceRange}), opts)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// The newest sequenced message before the page bounds its unsequenced replies from below
+	var previous *models.Message
+	hasMore := false
+	if params.Limit > 0 && int64(len(sequenced)) > params.Limit {
+		hasMore = true
+		if descending {
+			previous = &sequenced[params.Limit]
+		}
+		sequenced = sequenced[:params.Limit]
+	}
+	if descending {
+		slices.Reverse(sequenced)
+	}
+	if params.After > 0 {
+		previous, err = findSequencedMessage(ctx, collection, base, bson.M{"$gt": int64(0), "$lte": params.After}, -1)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
+	// A sequenced message after the page means replies written after the page's last
+	// message belong to the next page
+	later := !descending && hasMore
+	if params.Before > 0 && !later {
+		next, err := findSequencedMessage(ctx, collection, base, bson.M{"$gte": params.Before}, 1)
+		if err != nil {
+			return nil, false, err
+		}
+		later = next != nil
+	}
+	if later && len(sequenced) == 0 {
+		return sequenced, hasMore, nil
+	}
+
+	conditions := []bson.M{{"sequence": bson.M{"$in": bson.A{nil, 0}}}}
+	if previous != nil {
+		conditions = append(conditions, unsequencedSince("$gt", previous.Timestamp))
+	}
+	if later {
+		conditions = append(conditions, unsequencedSince("$lt", sequenced[len(sequenced)-1].Timestamp))
+	}
+
+	unsequenced, err := findMessages(ctx, collection, withConditions(base, conditions...), options.Find())
+	if err != nil {
+		return nil, false, err
+	}
 
+	return mergeUnsequenced(sequenced, unsequenced), hasMore, nil
+}
+
+func findMessages(ctx context.Context, collection *mongo.Collection, filter bson.M, opts *options.FindOptionsBuilder) ([]models.Message, error) {
 	cursor, err := collection.Find(ctx, filter, opts)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching messages: %v", err)
 	}
 	defer cursor.Close(ctx)
 
-	var messages []models.Message
+	messages := []models.Message{}
 	for cursor.Next(ctx) {
 		var message models.Message
 		if err := cursor.Decode(&message); err != nil {
@@ -43,10 +178,332 @@ func GetMessagesByConversationID(ctx context.Context, userID string, conversatio
 	if err := cursor.Err(); err != nil {
 		return nil, fmt.Errorf("cursor error: %v", err)
 	}
-
 	return messages, nil
 }
 
+// findSequencedMessage returns the first message in sequence order, ascending or
+// descending by direction, whose sequence matches sequenceRange, or nil if none does.
+// sequenceRange must exclude unsequenced messages.
+func findSequencedMessage(ctx context.Context, collection *mongo.Collection, base bson.M, sequenceRange bson.M, direction int) (*models.Message, error) {
+	var message models.Message
+	err := collection.FindOne(ctx,
+		withConditions(base, bson.M{"sequence": sequenceRange}),
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: direction}}),
+	).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching message: %v", err)
+	}
+	return &message, nil
+}
+
+// withConditions returns a copy of filter that also requires every condition
+func withConditions(filter bson.M, conditions ...bson.M) bson.M {
+	combined := make(bson.M, len(filter)+1)
+	for key, value := range filter {
+		combined[key] = value
+	}
+
+	all := make(bson.A, 0, len(conditions))
+	for _, condition := range conditions {
+		all = append(all, condition)
+	}
+	combined["$and"] = all
+	return combined
+}
+
+// mergeUnsequenced places unsequenced messages among messages already in sequence
+// order, each one after every sequenced message written no later than it
+func mergeUnsequenced(sequenced []models.Message, unsequenced []models.Message) []models.Message {
+	if len(unsequenced) == 0 {
+		return sequenced
+	}
+
+	// ObjectIDs embed a per-process counter, so they break ties between
+	// messages that were written within the same second
+	slices.SortStableFunc(unsequenced, func(a, b models.Message) int {
+		if c := cmp.Compare(messageSortTime(a.Timestamp), messageSortTime(b.Timestamp)); c != 0 {
+			return c
+		}
+		return strings.Compare(a.ID.Hex(), b.ID.Hex())
+	})
+
+	merged := make([]models.Message, 0, len(sequenced)+len(unsequenced))
+	for len(sequenced) > 0 || len(unsequenced) > 0 {
+		if len(unsequenced) == 0 || (len(sequenced) > 0 && messageSortTime(sequenced[0].Timestamp) <= messageSortTime(unsequenced[0].Timestamp)) {
+			merged = append(merged, sequenced[0])
+			sequenced = sequenced[1:]
+		} else {
+			merged = append(merged, unsequenced[0])
+			unsequenced = unsequenced[1:]
+		}
+	}
+	return merged
+}
+
+// GetMessageBySequence returns the message with the given sequence number, or nil if none exists
+func GetMessageBySequence(ctx context.Context, conversationID string, sequence int64) (*models.Message, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	var message models.Message
+	err := collection.FindOne(ctx, bson.M{
+		"conversation_id": conversationID,
+		"sequence":        sequence,
+	}).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching message: %v", err)
+	}
+
+	return &message, nil
+}
+
+// GetMessageByID returns a message in the conversation by its ID, or nil if none exists
+func GetMessageByID(ctx context.Context, conversationID string, messageID bson.ObjectID) (*models.Message, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	var message models.Message
+	err := collection.FindOne(ctx, bson.M{
+		"_id":             messageID,
+		"conversation_id": conversationID,
+	}).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching message: %v", err)
+	}
+
+	return &message, nil
+}
+
+// GetLatestUserMessage returns the newest user message that has not been superseded,
+// or nil if the conversation has none
+func GetLatestUserMessage(ctx context.Context, conversationID string) (*models.Message, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	var message models.Message
+	err := collection.FindOne(ctx,
+		bson.M{
+			"conversation_id": conversationID,
+			"sender":          models.SenderUser,
+			"superseded":      bson.M{"$ne": true},
+		},
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}),
+	).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching latest user message: %v", err)
+	}
+
+	return &message, nil
+}
+
+// GetAssistantReply returns the stored assistant message for a generation, or nil if
+// the AI service hasn't saved it yet
+func GetAssistantReply(ctx context.Context, conversationID string, generationID string) (*models.Message, error) {
+	if generationID == "" {
+		return nil, fmt.Errorf("generation ID is required to find an assistant reply")
+	}
+
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	var message models.Message
+	err := collection.FindOne(ctx, bson.M{
+		"conversation_id": conversationID,
+		"generation_id":   generationID,
+		"sender":          bson.M{"$ne": models.SenderUser},
+	}).Decode(&message)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error fetching assistant reply: %v", err)
+	}
+
+	return &message, nil
+}
+
+// SetMessageSuggestions stores follow-up prompts on a message
+func SetMessageSuggestions(ctx context.Context, messageID bson.ObjectID, suggestions []string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	_, err := collection.UpdateOne(ctx,
+		bson.M{"_id": messageID},
+		bson.M{"$set": bson.M{"suggestions": suggestions}},
+	)
+	if err != nil {
+		return fmt.Errorf("error saving message suggestions: %v", err)
+	}
+	return nil
+}
+
+// SupersedeMessagesFrom marks every message with a sequence at or after fromSequence
+// as superseded and returns how many were marked. Replies saved without a sequence are
+// marked when they were written at or after fromTimestamp, in Unix milliseconds.
+func SupersedeMessagesFrom(ctx context.Context, conversationID string, fromSequence int64, fromTimestamp int64) (int64, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+
+	result, err := collection.UpdateMany(ctx,
+		bson.M{
+			"conversation_id": conversationID,
+			"superseded":      bson.M{"$ne": true},
+			"$or": bson.A{
+				bson.M{"sequence": bson.M{"$gte": fromSequence}},
+				unsequencedSince("$gte", fromTimestamp),
+			},
+		},
+		bson.M{"$set": bson.M{"superseded": true}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error superseding messages: %v", err)
+	}
+
+	return result.ModifiedCount, nil
+}
+
+// unsequencedSince matches messages saved without a sequence, such as replies from
+// before the AI service stored them under reply_sequence, whose timestamp compares to
+// millis with op. Those replies have second-resolution timestamps, so both
+// resolutions are compared.
+func unsequencedSince(op string, millis int64) bson.M {
+	return bson.M{
+		"sequence": bson.M{"$in": bson.A{nil, 0}},
+		"$or": bson.A{
+			bson.M{"timestamp": bson.M{"$gte": SecondsCutoff, op: millis}},
+			bson.M{"timestamp": bson.M{"$lt": SecondsCutoff, op: millis / 1000}},
+		},
+	}
+}
+
+// CopyMessages copies every message up to and including upto into another
+// conversation, keeping sequence numbers, and returns how many were copied. Replies
+// saved without a sequence are copied when they were written no later than upto.
+// Documents are copied as-is so fields written by other services are preserved.
+func CopyMessages(ctx context.Context, fromConversationID string, toConversationID string, upto *models.Message) (int, error) {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+	uptoSequence := upto.Sequence
+
+	cursor, err := collection.Find(ctx,
+		bson.M{
+			"conversation_id": fromConversationID,
+			"superseded":      bson.M{"$ne": true},
+			"$or": bson.A{
+				bson.M{"sequence": bson.M{"$gt": 0, "$lte": uptoSequence}},
+				unsequencedSince("$lte", upto.Timestamp),
+			},
+		},
+		options.Find().SetSort(bson.D{{Key: "sequence", Value: 1}}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching messages to copy: %v", err)
+	}
+
+	var docs []bson.M
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("error decoding messages to copy: %v", err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	copies := make([]any, 0, len(docs))
+	for _, doc := range docs {
+		delete(doc, "_id")
+		doc["conversation_id"] = toConversationID
+		copies = append(copies, doc)
+	}
+
+	if _, err := collection.InsertMany(ctx, copies); err != nil {
+		return 0, fmt.Errorf("error copying messages: %v", err)
+	}
+
+	counters := MongoClient.Database(MongoDatabase).Collection(MessageCounterCollection)
+	_, err = counters.UpdateOne(ctx,
+		bson.M{"conversation_id": toConversationID},
+		bson.M{"$max": bson.M{"sequence": uptoSequence}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error updating sequence counter: %v", err)
+	}
+
+	return len(copies), nil
+}
+
+// MessageSearchParams scopes a full-text message search. From and To are optional.
+type MessageSearchParams struct {
+	Query           string
+	ConversationIDs []string
+	From            *time.Time
+	To              *time.Time
+	Limit           int64
+}
+
+// SearchMessages runs a text search over messages in the given conversations,
+// ordered by relevance
+func SearchMessages(ctx context.Context, params MessageSearchParams) ([]models.MessageSearchResult, error) {
+	if len(params.ConversationIDs) == 0 {
+		return []models.MessageSearchResult{}, nil
+	}
+
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+	filter := bson.M{
+		"$text":           bson.M{"$search": params.Query},
+		"conversation_id": bson.M{"$in": params.ConversationIDs},
+		"superseded":      bson.M{"$ne": true},
+	}
+
+	// AI replies and older messages store seconds, so the range is checked at both
+	// resolutions
+	millisRange := bson.M{"$gte": SecondsCutoff}
+	secondsRange := bson.M{"$lt": SecondsCutoff}
+	if params.From != nil {
+		millisRange["$gte"] = max(SecondsCutoff, params.From.UnixMilli())
+		secondsRange["$gte"] = params.From.UnixMilli() / 1000
+	}
+	if params.To != nil {
+		millisRange["$lte"] = params.To.UnixMilli()
+		secondsRange["$lte"] = params.To.UnixMilli() / 1000
+	}
+	if params.From != nil || params.To != nil {
+		filter["$or"] = bson.A{
+			bson.M{"timestamp": millisRange},
+			bson.M{"timestamp": secondsRange},
+		}
+	}
+
+	opts := options.Find().
+		SetProjection(bson.M{
+			"conversation_id": 1,
+			"sender":          1,
+			"message":         1,
+			"timestamp":       1,
+			"score":           bson.M{"$meta": "textScore"},
+		}).
+		SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+		SetLimit(params.Limit)
+
+	cursor, err := collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, fmt.Errorf("error searching messages: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	results := []models.MessageSearchResult{}
+	if err := cursor.All(ctx, &results); err != nil {
+		return nil, fmt.Errorf("error decoding search results: %v", err)
+	}
+
+	return results, nil
+}
+
 func DeleteMessages(ctx context.Context, conversationID string) error {
 	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
 	_, err := collection.DeleteMany(ctx, map[string]string{"conversation_id": conversationID})
@@ -56,6 +513,16 @@ func DeleteMessages(ctx context.Context, conversationID string) error {
 	return nil
 }
 
+// DeleteMessageCounter removes the sequence counter of a conversation that is being purged
+func DeleteMessageCounter(ctx context.Context, conversationID string) error {
+	collection := MongoClient.Database(MongoDatabase).Collection(MessageCounterCollection)
+	_, err := collection.DeleteOne(ctx, map[string]string{"conversation_id": conversationID})
+	if err != nil {
+		return fmt.Errorf("error deleting message counter: %v", err)
+	}
+	return nil
+}
+
 func DeleteMessagesByUserID(ctx context.Context, userId string) error {
 	collection := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
 	_, err := collection.DeleteMany(ctx, map[string]string{"user_id": userId})
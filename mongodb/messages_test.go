@@ -0,0 +1,48 @@
+package mongodb
+
+import (
+	"finance-chatbot/api/models"
+	"slices"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+)
+
+func TestMergeUnsequenced(t *testing.T) {
+	sequenced := []models.Message{
+		{Text: "first question", Sequence: 1, Timestamp: 1_700_000_000_000},
+		{Text: "second question", Sequence: 3, Timestamp: 1_700_000_060_000},
+		{Text: "third question", Sequence: 5, Timestamp: 1_700_000_120_500},
+	}
+	unsequenced := []models.Message{
+		// Seconds, written in the same second as the third question
+		{ID: bson.NewObjectID(), Text: "third answer", Timestamp: 1_700_000_120},
+		{ID: bson.NewObjectID(), Text: "first answer", Timestamp: 1_700_000_005_000},
+		// Seconds, written between the first and second questions
+		{ID: bson.NewObjectID(), Text: "second answer", Timestamp: 1_700_000_030},
+	}
+
+	merged := mergeUnsequenced(sequenced, unsequenced)
+
+	var got []string
+	for _, message := range merged {
+		got = append(got, message.Text)
+	}
+	want := []string{"first question", "first answer", "second answer", "second question", "third question", "third answer"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestMergeUnsequencedKeepsSequenceOrder(t *testing.T) {
+	// Sequence wins over timestamp, even when clocks disagree
+	sequenced := []models.Message{
+		{Text: "edited", Sequence: 1, Timestamp: 1_700_000_100_000},
+		{Text: "next", Sequence: 2, Timestamp: 1_700_000_050_000},
+	}
+
+	merged := mergeUnsequenced(sequenced, nil)
+	if len(merged) != 2 || merged[0].Text != "edited" || merged[1].Text != "next" {
+		t.Errorf("sequenced messages were reordered: %+v", merged)
+	}
+}
@@ -0,0 +1,169 @@
+package mongodb
+
+import (
+	"context"
+	"finance-chatbot/api/logger"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"go.uber.org/zap"
+)
+
+// SecondsCutoff separates legacy second-resolution timestamps from millisecond ones.
+// Any millisecond timestamp after 2001 is larger than this value.
+const SecondsCutoff int64 = 1_000_000_000_000
+
+// messageSortTime converts a message timestamp to milliseconds for ordering. A
+// second-resolution timestamp could be anywhere within that second, so the message
+// sorts after everything else written during it.
+func messageSortTime(timestamp int64) int64 {
+	if timestamp < SecondsCutoff {
+		return timestamp*1000 + 999
+	}
+	return timestamp
+}
+
+// BackfillMessageSequences assigns sequence numbers to messages written without one
+// and converts their second-resolution timestamps to milliseconds. Messages that
+// already have a sequence keep it, since forks and reserved reply sequences refer to
+// those numbers. New numbers are taken above the conversation's counter, so in a
+// conversation that already has sequenced messages the backfilled ones are numbered
+// after them. Conversations whose messages all carry a sequence are left untouched,
+// so the backfill can safely be run more than once.
+func BackfillMessageSequences(ctx context.Context) (int, error) {
+	messages := MongoClient.Database(MongoDatabase).Collection(MessageCollection)
+	counters := MongoClient.Database(MongoDatabase).Collection(MessageCounterCollection)
+
+	result := messages.Distinct(ctx, "conversation_id", bson.M{
+		"sequence": bson.M{"$in": bson.A{nil, 0}},
+	})
+	var conversationIDs []string
+	if err := result.Decode(&conversationIDs); err != nil {
+		return 0, fmt.Errorf("error listing conversations to backfill: %v", err)
+	}
+
+	updated := 0
+	for _, conversationID := range conversationIDs {
+		count, err := backfillConversation(ctx, messages, counters, conversationID)
+		if err != nil {
+			return updated, err
+		}
+		updated += count
+
+		logger.Get().Info("backfilled message sequences",
+			zap.String("conversation_id", conversationID),
+			zap.Int("message_count", count))
+	}
+
+	return updated, nil
+}
+
+type backfillMessage struct {
+	ID        bson.ObjectID `bson:"_id"`
+	Timestamp int64         `bson:"timestamp"`
+	// sortTime is when the message was written at its latest possible millisecond
+	sortTime int64
+}
+
+// backfillConversation numbers a conversation's unsequenced messages in the order
+// they were written, from a block of sequences reserved above the counter
+func backfillConversation(ctx context.Context, messages *mongo.Collection, counters *mongo.Collection, conversationID string) (int, error) {
+	cursor, err := messages.Find(ctx,
+		bson.M{
+			"conversation_id": conversationID,
+			"sequence":        bson.M{"$in": bson.A{nil, 0}},
+		},
+		options.Find().SetProjection(bson.M{"_id": 1, "timestamp": 1}),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching messages for %s: %v", conversationID, err)
+	}
+
+	var docs []backfillMessage
+	if err := cursor.All(ctx, &docs); err != nil {
+		return 0, fmt.Errorf("error decoding messages for %s: %v", conversationID, err)
+	}
+	if len(docs) == 0 {
+		return 0, nil
+	}
+
+	for i := range docs {
+		docs[i].sortTime = messageSortTime(docs[i].Timestamp)
+		if docs[i].Timestamp < SecondsCutoff {
+			docs[i].Timestamp *= 1000
+		}
+	}
+
+	// ObjectIDs embed a per-process counter, so they break ties between
+	// messages that were written within the same second
+	sort.SliceStable(docs, func(i, j int) bool {
+		if docs[i].sortTime != docs[j].sortTime {
+			return docs[i].sortTime < docs[j].sortTime
+		}
+		return docs[i].ID.Hex() < docs[j].ID.Hex()
+	})
+
+	first, err := reserveSequences(ctx, messages, counters, conversationID, int64(len(docs)))
+	if err != nil {
+		return 0, err
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(docs))
+	for i, doc := range docs {
+		writes = append(writes, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": doc.ID, "sequence": bson.M{"$in": bson.A{nil, 0}}}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"sequence":  first + int64(i),
+				"timestamp": doc.Timestamp,
+			}}))
+	}
+
+	result, err := messages.BulkWrite(ctx, writes)
+	if err != nil {
+		return 0, fmt.Errorf("error writing sequences for %s: %v", conversationID, err)
+	}
+
+	return int(result.ModifiedCount), nil
+}
+
+// reserveSequences reserves count sequence numbers for a conversation and returns the
+// first. The counter is first raised with $max to the highest sequence in use, in
+// case messages were numbered without it, and then advanced past the block.
+func reserveSequences(ctx context.Context, messages *mongo.Collection, counters *mongo.Collection, conversationID string, count int64) (int64, error) {
+	var highest struct {
+		Sequence int64 `bson:"sequence"`
+	}
+	err := messages.FindOne(ctx,
+		bson.M{"conversation_id": conversationID, "sequence": bson.M{"$gt": 0}},
+		options.FindOne().SetSort(bson.D{{Key: "sequence", Value: -1}}).SetProjection(bson.M{"sequence": 1}),
+	).Decode(&highest)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return 0, fmt.Errorf("error finding highest sequence for %s: %v", conversationID, err)
+	}
+
+	_, err = counters.UpdateOne(ctx,
+		bson.M{"conversation_id": conversationID},
+		bson.M{"$max": bson.M{"sequence": highest.Sequence}},
+		options.UpdateOne().SetUpsert(true),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error updating sequence counter for %s: %v", conversationID, err)
+	}
+
+	var counter struct {
+		Sequence int64 `bson:"sequence"`
+	}
+	err = counters.FindOneAndUpdate(ctx,
+		bson.M{"conversation_id": conversationID},
+		bson.M{"$inc": bson.M{"sequence": count}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&counter)
+	if err != nil {
+		return 0, fmt.Errorf("error reserving sequences for %s: %v", conversationID, err)
+	}
+
+	return counter.Sequence - count + 1, nil
+}
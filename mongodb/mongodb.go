@@ -5,6 +5,7 @@ import (
 	"finance-chatbot/api/logger"
 	"fmt"
 	"os"
+	"time"
 
 	"go.mongodb.org/mongo-driver/v2/mongo"
 	"go.mongodb.org/mongo-driver/v2/mongo/options"
@@ -12,10 +13,14 @@ import (
 )
 
 const (
-	ContextCollection  string = "contexts"
-	MessageCollection  string = "messages"
-	UserInfoCollection string = "user_info"
-	MongoDatabase      string = "conversations"
+	ContextCollection        string = "contexts"
+	FeedbackCollection       string = "feedback"
+	InvestmentCollection     string = "investments"
+	LiabilitiesCollection    string = "liabilities"
+	MessageCollection        string = "messages"
+	MessageCounterCollection string = "message_counters"
+	UserInfoCollection       string = "user_info"
+	MongoDatabase            string = "conversations"
 )
 
 var (
@@ -42,6 +47,15 @@ func InitMongoDB() error {
 	MongoClient = client
 	logger.Get().Info("successfully connected to MongoDB",
 		zap.String("uri", mongoURI))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := EnsureIndexes(ctx); err != nil {
+		logger.Get().Error("failed to ensure MongoDB indexes",
+			zap.Error(err))
+		return err
+	}
+
 	return nil
 }
 
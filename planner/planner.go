@@ -0,0 +1,326 @@
+// Package planner builds deterministic debt payoff schedules. Every month interest
+// accrues on each balance, every debt gets its minimum payment and whatever is left
+// of the budget goes to debts in the strategy's priority order. Minimums freed up by
+// paid-off debts roll into the next debt, since the budget stays the same.
+package planner
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+type Strategy string
+
+const (
+	// Avalanche pays the highest APR first, which costs the least interest
+	Avalanche Strategy = "avalanche"
+	// Snowball pays the smallest balance first, which clears accounts soonest
+	Snowball Strategy = "snowball"
+	// Custom pays debts in the order the caller gives
+	Custom Strategy = "custom"
+)
+
+const (
+	// MaxMonths caps the schedule at 50 years
+	MaxMonths = 600
+
+	// Used when a debt's minimum payment isn't known: interest plus 1% of the
+	// balance, and never less than the floor. This is how most card issuers set it.
+	defaultMinimumRate  = 0.01
+	defaultMinimumFloor = 25.0
+)
+
+var (
+	ErrUnknownStrategy = errors.New("unknown strategy")
+	ErrInvalidOrder    = errors.New("custom order lists an unknown debt")
+	ErrBudgetTooLow    = errors.New("budget does not cover the minimum payments")
+	ErrNoPayoff        = errors.New("debts are not paid off within the maximum schedule")
+)
+
+// Debt is one balance to pay off. APR is an annual percentage such as 24.99. A
+// MinimumPayment of 0 means unknown; the usual card minimum is assumed.
+type Debt struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	Balance        float64 `json:"balance"`
+	APR            float64 `json:"apr"`
+	MinimumPayment float64 `json:"minimum_payment"`
+}
+
+// Payment is what one debt received in one month. Balance is what is left after it.
+type Payment struct {
+	ID        string  `json:"id"`
+	Payment   float64 `json:"payment"`
+	Interest  float64 `json:"interest"`
+	Principal float64 `json:"principal"`
+	Balance   float64 `json:"balance"`
+}
+
+type Month struct {
+	Month            int       `json:"month"`
+	Date             string    `json:"date"`
+	Payments         []Payment `json:"payments"`
+	TotalPayment     float64   `json:"total_payment"`
+	TotalInterest    float64   `json:"total_interest"`
+	RemainingBalance float64   `json:"remaining_balance"`
+}
+
+// DebtResult summarizes how one debt is paid off
+type DebtResult struct {
+	ID             string  `json:"id"`
+	Name           string  `json:"name"`
+	StartBalance   float64 `json:"start_balance"`
+	APR            float64 `json:"apr"`
+	MinimumPayment float64 `json:"minimum_payment"`
+	PayoffMonth    int     `json:"payoff_month"`
+	PayoffDate     string  `json:"payoff_date"`
+	InterestPaid   float64 `json:"interest_paid"`
+	TotalPaid      float64 `json:"total_paid"`
+}
+
+type Plan struct {
+	Strategy      Strategy     `json:"strategy"`
+	MonthlyBudget float64      `json:"monthly_budget"`
+	Months        int          `json:"months"`
+	PayoffDate    string       `json:"payoff_date"`
+	TotalInterest float64      `json:"total_interest"`
+	TotalPaid     float64      `json:"total_paid"`
+	Debts         []DebtResult `json:"debts"`
+	Schedule      []Month      `json:"schedule"`
+}
+
+// debtState tracks a debt through the schedule
+type debtState struct {
+	Debt
+	balance float64
+	result  *DebtResult
+}
+
+// Build plans paying off debts with budget each month, starting the month after
+// start. order is only used by Custom: debts it lists are paid first in that order,
+// then the rest by avalanche. Debts without a positive balance are skipped.
+func Build(debts []Debt, budget float64, strategy Strategy, order []string, start time.Time) (*Plan, error) {
+	states := make([]*debtState, 0, len(debts))
+	for _, debt := range debts {
+		if roundCents(debt.Balance) <= 0 {
+			continue
+		}
+		if debt.MinimumPayment <= 0 {
+			debt.MinimumPayment = defaultMinimum(debt)
+		}
+		debt.Balance = roundCents(debt.Balance)
+		debt.MinimumPayment = roundCents(debt.MinimumPayment)
+
+		states = append(states, &debtState{
+			Debt:    debt,
+			balance: debt.Balance,
+			result: &DebtResult{
+				ID:             debt.ID,
+				Name:           debt.Name,
+				StartBalance:   debt.Balance,
+				APR:            debt.APR,
+				MinimumPayment: debt.MinimumPayment,
+			},
+		})
+	}
+
+	if err := prioritize(states, strategy, order); err != nil {
+		return nil, err
+	}
+
+	var minimums float64
+	for _, state := range states {
+		minimums += math.Min(state.MinimumPayment, state.balance)
+	}
+	if budget < roundCents(minimums) {
+		return nil, fmt.Errorf("%w: minimums total %.2f", ErrBudgetTooLow, roundCents(minimums))
+	}
+
+	plan := &Plan{
+		Strategy:      strategy,
+		MonthlyBudget: budget,
+		Debts:         make([]DebtResult, 0, len(states)),
+		Schedule:      []Month{},
+	}
+
+	firstMonth := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, 1, 0)
+	remaining := totalBalance(states)
+
+	for month := 1; remaining > 0; month++ {
+		if month > MaxMonths {
+			return nil, ErrNoPayoff
+		}
+
+		date := firstMonth.AddDate(0, month-1, 0).Format("2006-01")
+		schedule := payMonth(states, budget)
+		schedule.Month = month
+		schedule.Date = date
+
+		for _, state := range states {
+			if state.balance == 0 && state.result.PayoffMonth == 0 {
+				state.result.PayoffMonth = month
+				state.result.PayoffDate = date
+			}
+		}
+
+		// A month that doesn't reduce the total never will, since nothing changes
+		// from one month to the next but the balances
+		if schedule.RemainingBalance >= remaining {
+			return nil, ErrNoPayoff
+		}
+		remaining = schedule.RemainingBalance
+
+		plan.Schedule = append(plan.Schedule, schedule)
+		plan.TotalInterest += schedule.TotalInterest
+		plan.TotalPaid += schedule.TotalPayment
+	}
+
+	plan.Months = len(plan.Schedule)
+	if plan.Months > 0 {
+		plan.PayoffDate = plan.Schedule[plan.Months-1].Date
+	}
+	plan.TotalInterest = roundCents(plan.TotalInterest)
+	plan.TotalPaid = roundCents(plan.TotalPaid)
+	for _, state := range states {
+		state.result.InterestPaid = roundCents(state.result.InterestPaid)
+		state.result.TotalPaid = roundCents(state.result.TotalPaid)
+		plan.Debts = append(plan.Debts, *state.result)
+	}
+
+	return plan, nil
+}
+
+// payMonth accrues a month of interest, pays every minimum and spends the rest of
+// the budget in priority order. states must already be prioritized.
+func payMonth(states []*debtState, budget float64) Month {
+	schedule := Month{Payments: []Payment{}}
+	payments := make([]Payment, len(states))
+
+	available := budget
+	for i, state := range states {
+		if state.balance == 0 {
+			continue
+		}
+
+		interest := roundCents(state.balance * state.APR / 100 / 12)
+		state.balance = roundCents(state.balance + interest)
+
+		payment := math.Min(state.MinimumPayment, state.balance)
+		state.balance = roundCents(state.balance - payment)
+		available = roundCents(available - payment)
+
+		payments[i] = Payment{ID: state.ID, Payment: payment, Interest: interest}
+	}
+
+	for i, state := range states {
+		if available <= 0 {
+			break
+		}
+		if state.balance == 0 {
+			continue
+		}
+
+		extra := math.Min(available, state.balance)
+		state.balance = roundCents(state.balance - extra)
+		available = roundCents(available - extra)
+		payments[i].Payment = roundCents(payments[i].Payment + extra)
+	}
+
+	for i, state := range states {
+		payment := payments[i]
+		if payment.ID == "" {
+			continue
+		}
+
+		payment.Principal = roundCents(payment.Payment - payment.Interest)
+		payment.Balance = state.balance
+		schedule.Payments = append(schedule.Payments, payment)
+
+		state.result.InterestPaid += payment.Interest
+		state.result.TotalPaid += payment.Payment
+		schedule.TotalPayment += payment.Payment
+		schedule.TotalInterest += payment.Interest
+	}
+
+	schedule.TotalPayment = roundCents(schedule.TotalPayment)
+	schedule.TotalInterest = roundCents(schedule.TotalInterest)
+	schedule.RemainingBalance = totalBalance(states)
+	return schedule
+}
+
+// prioritize sorts states into the order extra payments go in
+func prioritize(states []*debtState, strategy Strategy, order []string) error {
+	avalanche := func(a, b *debtState) bool {
+		if a.APR != b.APR {
+			return a.APR > b.APR
+		}
+		return a.balance < b.balance
+	}
+
+	switch strategy {
+	case Avalanche:
+		sort.SliceStable(states, func(i, j int) bool { return avalanche(states[i], states[j]) })
+
+	case Snowball:
+		sort.SliceStable(states, func(i, j int) bool {
+			if states[i].balance != states[j].balance {
+				return states[i].balance < states[j].balance
+			}
+			return states[i].APR > states[j].APR
+		})
+
+	case Custom:
+		rank := make(map[string]int, len(order))
+		for i, id := range order {
+			rank[id] = i
+		}
+
+		known := make(map[string]bool, len(states))
+		for _, state := range states {
+			known[state.ID] = true
+		}
+		for _, id := range order {
+			if !known[id] {
+				return fmt.Errorf("%w: %s", ErrInvalidOrder, id)
+			}
+		}
+
+		sort.SliceStable(states, func(i, j int) bool {
+			ri, iListed := rank[states[i].ID]
+			rj, jListed := rank[states[j].ID]
+			switch {
+			case iListed && jListed:
+				return ri < rj
+			case iListed != jListed:
+				return iListed
+			default:
+				return avalanche(states[i], states[j])
+			}
+		})
+
+	default:
+		return fmt.Errorf("%w: %q", ErrUnknownStrategy, strategy)
+	}
+
+	return nil
+}
+
+func defaultMinimum(debt Debt) float64 {
+	interest := debt.Balance * debt.APR / 100 / 12
+	return math.Max(defaultMinimumFloor, interest+debt.Balance*defaultMinimumRate)
+}
+
+func totalBalance(states []*debtState) float64 {
+	var total float64
+	for _, state := range states {
+		total += state.balance
+	}
+	return roundCents(total)
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
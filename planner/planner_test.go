@@ -0,0 +1,153 @@
+package planner
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+var start = time.Date(2024, time.March, 15, 0, 0, 0, 0, time.UTC)
+
+// Card costs more interest, loan has the smaller balance
+var (
+	card = Debt{ID: "card", Name: "Card", Balance: 1000, APR: 25, MinimumPayment: 25}
+	loan = Debt{ID: "loan", Name: "Loan", Balance: 500, APR: 10, MinimumPayment: 25}
+	auto = Debt{ID: "auto", Name: "Auto", Balance: 3000, APR: 5, MinimumPayment: 100}
+)
+
+func TestBuildPriority(t *testing.T) {
+	tests := []struct {
+		name     string
+		debts    []Debt
+		strategy Strategy
+		order    []string
+		want     []string
+	}{
+		{"avalanche pays the highest APR first", []Debt{loan, card}, Avalanche, nil, []string{"card", "loan"}},
+		{"snowball pays the smallest balance first", []Debt{card, loan}, Snowball, nil, []string{"loan", "card"}},
+		{"custom follows the order", []Debt{card, loan, auto}, Custom, []string{"auto", "loan"}, []string{"auto", "loan", "card"}},
+		{"custom falls back to avalanche", []Debt{auto, loan, card}, Custom, []string{"loan"}, []string{"loan", "card", "auto"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Build(tt.debts, 400, tt.strategy, tt.order, start)
+			if err != nil {
+				t.Fatalf("building plan: %v", err)
+			}
+
+			payments := plan.Schedule[0].Payments
+			if len(payments) != len(tt.want) {
+				t.Fatalf("got %d payments in the first month, want %d", len(payments), len(tt.want))
+			}
+			for i, id := range tt.want {
+				if payments[i].ID != id {
+					t.Errorf("payment %d goes to %s, want %s", i, payments[i].ID, id)
+				}
+			}
+
+			// Everything above the minimums goes to the first debt
+			var otherMinimums float64
+			for _, payment := range payments[1:] {
+				otherMinimums += payment.Payment
+			}
+			if got, want := payments[0].Payment, roundCents(400-otherMinimums); got != want {
+				t.Errorf("first debt paid %.2f, want %.2f", got, want)
+			}
+		})
+	}
+}
+
+func TestBuildAvalancheCostsLessInterest(t *testing.T) {
+	avalanche, err := Build([]Debt{card, loan}, 200, Avalanche, nil, start)
+	if err != nil {
+		t.Fatalf("building avalanche plan: %v", err)
+	}
+	snowball, err := Build([]Debt{card, loan}, 200, Snowball, nil, start)
+	if err != nil {
+		t.Fatalf("building snowball plan: %v", err)
+	}
+
+	if avalanche.TotalInterest >= snowball.TotalInterest {
+		t.Errorf("avalanche interest %.2f, snowball %.2f; want avalanche lower", avalanche.TotalInterest, snowball.TotalInterest)
+	}
+
+	for _, plan := range []*Plan{avalanche, snowball} {
+		last := plan.Schedule[len(plan.Schedule)-1]
+		if last.RemainingBalance != 0 {
+			t.Errorf("%s: %.2f left after the last month", plan.Strategy, last.RemainingBalance)
+		}
+		if plan.PayoffDate != last.Date || plan.Months != last.Month {
+			t.Errorf("%s: payoff %s after %d months, last month is %s (%d)", plan.Strategy, plan.PayoffDate, plan.Months, last.Date, last.Month)
+		}
+		if got, want := plan.TotalPaid, roundCents(card.Balance+loan.Balance+plan.TotalInterest); got != want {
+			t.Errorf("%s: total paid %.2f, want balances plus interest %.2f", plan.Strategy, got, want)
+		}
+		for _, debt := range plan.Debts {
+			if debt.PayoffMonth == 0 || debt.PayoffMonth > plan.Months {
+				t.Errorf("%s: %s paid off in month %d of %d", plan.Strategy, debt.ID, debt.PayoffMonth, plan.Months)
+			}
+		}
+	}
+
+	if first := avalanche.Schedule[0].Date; first != "2024-04" {
+		t.Errorf("schedule starts %s, want the month after start", first)
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		debts    []Debt
+		budget   float64
+		strategy Strategy
+		order    []string
+		want     error
+	}{
+		{"budget below minimums", []Debt{card, loan}, 49.99, Avalanche, nil, ErrBudgetTooLow},
+		{"unknown strategy", []Debt{card}, 100, "fastest", nil, ErrUnknownStrategy},
+		{"custom order lists an unknown debt", []Debt{card}, 100, Custom, []string{"mortgage"}, ErrInvalidOrder},
+		{
+			"interest outgrows the payments",
+			[]Debt{{ID: "card", Balance: 10000, APR: 30, MinimumPayment: 25}},
+			100, Avalanche, nil, ErrNoPayoff,
+		},
+		{
+			"payoff takes longer than the maximum schedule",
+			[]Debt{{ID: "loan", Balance: 100000, MinimumPayment: 100}},
+			100, Avalanche, nil, ErrNoPayoff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Build(tt.debts, tt.budget, tt.strategy, tt.order, start)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("got plan %v, error %v; want %v", plan != nil, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildDefaultMinimum(t *testing.T) {
+	plan, err := Build([]Debt{
+		{ID: "small", Balance: 300, APR: 12},
+		{ID: "large", Balance: 10000, APR: 24},
+		{ID: "empty", Balance: 0, APR: 20},
+	}, 1000, Avalanche, nil, start)
+	if err != nil {
+		t.Fatalf("building plan: %v", err)
+	}
+
+	if len(plan.Debts) != 2 {
+		t.Fatalf("got %d debts, want the empty one skipped", len(plan.Debts))
+	}
+
+	// Interest plus 1% of the balance, but never below the floor
+	want := map[string]float64{"large": 300, "small": defaultMinimumFloor}
+	for _, debt := range plan.Debts {
+		if debt.MinimumPayment != want[debt.ID] {
+			t.Errorf("%s: minimum %.2f, want %.2f", debt.ID, debt.MinimumPayment, want[debt.ID])
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Package portfolio turns Plaid holdings snapshots into positions, an asset class
+// allocation and concentration figures.
+package portfolio
+
+import (
+	"finance-chatbot/api/models"
+	"sort"
+)
+
+// Asset classes positions are grouped into. Funds are kept apart from equity because
+// Plaid doesn't say what an ETF or mutual fund holds.
+const (
+	AssetClassCash        = "cash"
+	AssetClassEquity      = "equity"
+	AssetClassFund        = "fund"
+	AssetClassFixedIncome = "fixed_income"
+	AssetClassCrypto      = "crypto"
+	AssetClassDerivative  = "derivative"
+	AssetClassOther       = "other"
+)
+
+const (
+	// ConcentrationThreshold is the weight above which a single security makes the
+	// portfolio concentrated
+	ConcentrationThreshold = 0.2
+	// topPositionCount is how many securities concentration and summaries list
+	topPositionCount = 5
+)
+
+// AssetClass maps a security to its asset class. Cash equivalents such as money
+// market funds count as cash whatever their type.
+func AssetClass(security models.Security) string {
+	if security.IsCashEquivalent {
+		return AssetClassCash
+	}
+
+	switch security.Type {
+	case "cash":
+		return AssetClassCash
+	case "equity":
+		return AssetClassEquity
+	case "etf", "mutual fund":
+		return AssetClassFund
+	case "fixed income":
+		return AssetClassFixedIncome
+	case "cryptocurrency":
+		return AssetClassCrypto
+	case "derivative":
+		return AssetClassDerivative
+	default:
+		return AssetClassOther
+	}
+}
+
+// Positions joins every holding in the snapshots with its security, largest value
+// first
+func Positions(snapshots []*models.InvestmentSnapshot) []models.Position {
+	positions := []models.Position{}
+	for _, snapshot := range snapshots {
+		securities := make(map[string]models.Security, len(snapshot.Securities))
+		for _, security := range snapshot.Securities {
+			securities[security.SecurityID] = security
+		}
+
+		for _, holding := range snapshot.Holdings {
+			security := securities[holding.SecurityID]
+			positions = append(positions, models.Position{
+				AccountID:    holding.AccountID,
+				SecurityID:   holding.SecurityID,
+				Name:         security.Name,
+				TickerSymbol: security.TickerSymbol,
+				AssetClass:   AssetClass(security),
+				Quantity:     holding.Quantity,
+				Price:        holding.InstitutionPrice,
+				Value:        holding.InstitutionValue,
+				CostBasis:    holding.CostBasis,
+			})
+		}
+	}
+
+	setWeights(positions, totalValue(positions))
+	sortByValue(positions)
+	return positions
+}
+
+// Allocate groups positions by asset class and measures how concentrated the
+// non-cash part of the portfolio is
+func Allocate(positions []models.Position) models.Allocation {
+	total := totalValue(positions)
+
+	byClass := make(map[string]float64)
+	for _, position := range positions {
+		byClass[position.AssetClass] += position.Value
+	}
+
+	classes := make([]models.AssetClassAllocation, 0, len(byClass))
+	for class, value := range byClass {
+		classes = append(classes, models.AssetClassAllocation{
+			AssetClass: class,
+			Value:      value,
+			Weight:     weight(value, total),
+		})
+	}
+	sort.Slice(classes, func(i, j int) bool {
+		if classes[i].Value != classes[j].Value {
+			return classes[i].Value > classes[j].Value
+		}
+		return classes[i].AssetClass < classes[j].AssetClass
+	})
+
+	return models.Allocation{
+		TotalValue:    total,
+		AssetClasses:  classes,
+		Concentration: concentration(positions),
+	}
+}
+
+// Summarize condenses the snapshots for a conversation Context. It returns nil when
+// the user has no holdings.
+func Summarize(snapshots []*models.InvestmentSnapshot) *models.InvestmentSummary {
+	positions := Positions(snapshots)
+	if len(positions) == 0 {
+		return nil
+	}
+
+	allocation := Allocate(positions)
+
+	// The oldest snapshot bounds how current the summary is
+	var updatedAt int64
+	for _, snapshot := range snapshots {
+		if updatedAt == 0 || snapshot.UpdatedAt < updatedAt {
+			updatedAt = snapshot.UpdatedAt
+		}
+	}
+
+	return &models.InvestmentSummary{
+		TotalValue:   allocation.TotalValue,
+		AssetClasses: allocation.AssetClasses,
+		TopHoldings:  allocation.Concentration.TopPositions,
+		Concentrated: allocation.Concentration.Concentrated,
+		UpdatedAt:    updatedAt,
+	}
+}
+
+// concentration merges positions in the same security across accounts, since they
+// are the same exposure, and leaves cash out
+func concentration(positions []models.Position) models.Concentration {
+	index := make(map[string]int)
+	var securities []models.Position
+	for _, position := range positions {
+		if position.AssetClass == AssetClassCash {
+			continue
+		}
+
+		if i, ok := index[position.SecurityID]; ok {
+			securities[i].Quantity += position.Quantity
+			securities[i].Value += position.Value
+			securities[i].CostBasis = addCostBasis(securities[i].CostBasis, position.CostBasis)
+			continue
+		}
+
+		position.AccountID = ""
+		index[position.SecurityID] = len(securities)
+		securities = append(securities, position)
+	}
+
+	total := totalValue(securities)
+	setWeights(securities, total)
+	sortByValue(securities)
+
+	result := models.Concentration{TopPositions: []models.Position{}}
+	for i, position := range securities {
+		result.HHI += position.Weight * position.Weight
+		if i < topPositionCount {
+			result.TopPositions = append(result.TopPositions, position)
+			result.TopFiveWeight += position.Weight
+		}
+	}
+	if len(securities) > 0 {
+		result.LargestWeight = securities[0].Weight
+		result.Concentrated = result.LargestWeight > ConcentrationThreshold
+	}
+	return result
+}
+
+func addCostBasis(a, b *float64) *float64 {
+	if a == nil || b == nil {
+		return nil
+	}
+	sum := *a + *b
+	return &sum
+}
+
+func totalValue(positions []models.Position) float64 {
+	var total float64
+	for _, position := range positions {
+		total += position.Value
+	}
+	return total
+}
+
+func setWeights(positions []models.Position, total float64) {
+	for i := range positions {
+		positions[i].Weight = weight(positions[i].Value, total)
+	}
+}
+
+func weight(value, total float64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return value / total
+}
+
+func sortByValue(positions []models.Position) {
+	sort.SliceStable(positions, func(i, j int) bool {
+		return positions[i].Value > positions[j].Value
+	})
+}
@@ -0,0 +1,392 @@
+package qdrant
+
+import (
+	"context"
+	"errors"
+	"finance-chatbot/api/logger"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/qdrant/go-client/qdrant"
+	"go.uber.org/zap"
+)
+
+// TransactionsDistance is the similarity metric for transaction vectors. Both
+// embedders produce normalised vectors, so cosine is the right fit.
+const TransactionsDistance = qdrant.Distance_Cosine
+
+// copyBatchSize is how many points are read and written at a time when copying
+const copyBatchSize = 256
+
+// ErrSchemaMismatch is returned when the transactions collection was created with a
+// different vector size or distance than the configured embedder uses
+var ErrSchemaMismatch = errors.New("transactions collection does not match the embedder")
+
+// transactionPayloadIndexes are the payload fields searches and deletes filter on
+var transactionPayloadIndexes = []string{
+	userIDField,
+	itemIDField,
+	accountIDField,
+	"metadata.category",
+}
+
+// EnsureTransactionsCollection makes sure the transactions collection exists, matches
+// the embedder's dimension and has its payload indexes. The collection is always a
+// versioned one behind the TransactionsCollection alias so it can later be re-embedded
+// without downtime. An unversioned collection from before aliases is copied into the
+// first version and replaced by the alias; run a single instance for that first start.
+func EnsureTransactionsCollection(ctx context.Context, dimension uint64) error {
+	if QdrantClient == nil {
+		return fmt.Errorf("QdrantClient is not initialized")
+	}
+
+	target, aliased, err := resolveAlias(ctx, TransactionsCollection)
+	if err != nil {
+		return err
+	}
+
+	if !aliased {
+		exists, err := QdrantClient.CollectionExists(ctx, TransactionsCollection)
+		if err != nil {
+			return fmt.Errorf("failed to check for collection %s: %w", TransactionsCollection, err)
+		}
+
+		target = versionedCollectionName(1)
+		if exists {
+			if err := adoptUnversionedCollection(ctx, target); err != nil {
+				return err
+			}
+		} else {
+			if err := createTransactionsCollection(ctx, target, dimension, TransactionsDistance); err != nil {
+				return err
+			}
+			if err := QdrantClient.CreateAlias(ctx, TransactionsCollection, target); err != nil {
+				return fmt.Errorf("failed to create alias %s: %w", TransactionsCollection, err)
+			}
+			logger.Get().Info("created transactions collection",
+				zap.String("collection", target),
+				zap.Uint64("dimension", dimension))
+		}
+	}
+
+	if err := verifyCollection(ctx, target, dimension); err != nil {
+		return err
+	}
+
+	return ensurePayloadIndexes(ctx, target)
+}
+
+// adoptUnversionedCollection copies the unversioned transactions collection into
+// target as-is, then deletes it and lets the alias take its name. The copy keeps the
+// old vector size and distance, so a schema mismatch is still reported afterwards and
+// fixed by migrating. Only the moment between the delete and the alias is without a
+// collection; nothing is lost if the copy fails.
+func adoptUnversionedCollection(ctx context.Context, target string) error {
+	info, err := QdrantClient.GetCollectionInfo(ctx, TransactionsCollection)
+	if err != nil {
+		return fmt.Errorf("failed to get collection %s: %w", TransactionsCollection, err)
+	}
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return fmt.Errorf("%w: %s has no default vector", ErrSchemaMismatch, TransactionsCollection)
+	}
+
+	if err := deleteStaleCollection(ctx, target); err != nil {
+		return err
+	}
+	if err := createTransactionsCollection(ctx, target, params.GetSize(), params.GetDistance()); err != nil {
+		return err
+	}
+
+	copied, err := copyPoints(ctx, TransactionsCollection, target)
+	if err != nil {
+		if deleteErr := QdrantClient.DeleteCollection(ctx, target); deleteErr != nil {
+			logger.Get().Error("failed to clean up collection after failed copy",
+				zap.String("collection", target),
+				zap.Error(deleteErr))
+		}
+		return fmt.Errorf("failed to copy %s to %s: %w", TransactionsCollection, target, err)
+	}
+
+	if err := QdrantClient.DeleteCollection(ctx, TransactionsCollection); err != nil {
+		return fmt.Errorf("failed to delete unversioned collection %s: %w", TransactionsCollection, err)
+	}
+	if err := QdrantClient.CreateAlias(ctx, TransactionsCollection, target); err != nil {
+		return fmt.Errorf("failed to create alias %s: %w", TransactionsCollection, err)
+	}
+
+	logger.Get().Info("moved unversioned transactions collection behind alias",
+		zap.String("collection", target),
+		zap.Int("points", copied))
+	return nil
+}
+
+// copyPoints copies every point with its vector and payload from one collection to
+// another and returns how many were copied
+func copyPoints(ctx context.Context, from string, to string) (int, error) {
+	limit := uint32(copyBatchSize)
+	wait := true
+
+	var offset *qdrant.PointId
+	copied := 0
+	for {
+		retrieved, next, err := QdrantClient.ScrollAndOffset(ctx, &qdrant.ScrollPoints{
+			CollectionName: from,
+			Offset:         offset,
+			Limit:          &limit,
+			WithPayload:    qdrant.NewWithPayload(true),
+			WithVectors:    qdrant.NewWithVectors(true),
+		})
+		if err != nil {
+			return copied, fmt.Errorf("failed to scroll %s: %w", from, err)
+		}
+
+		if len(retrieved) > 0 {
+			points := make([]*qdrant.PointStruct, len(retrieved))
+			for i, point := range retrieved {
+				vector := point.GetVectors().GetVector()
+				data := vector.GetDense().GetData()
+				if data == nil {
+					data = vector.GetData()
+				}
+				points[i] = &qdrant.PointStruct{
+					Id:      point.GetId(),
+					Vectors: qdrant.NewVectors(data...),
+					Payload: point.GetPayload(),
+				}
+			}
+
+			_, err = QdrantClient.Upsert(ctx, &qdrant.UpsertPoints{
+				CollectionName: to,
+				Points:         points,
+				Wait:           &wait,
+			})
+			if err != nil {
+				return copied, fmt.Errorf("failed to write points to %s: %w", to, err)
+			}
+			copied += len(points)
+		}
+
+		if next == nil {
+			return copied, nil
+		}
+		offset = next
+	}
+}
+
+// MigrateTransactionsCollection re-embeds transactions into a new versioned collection
+// and points the TransactionsCollection alias at it once populate succeeds. populate
+// receives the new collection's name and must fill it. While it runs, the new
+// collection is also reachable through the MigrationCollection alias, and indexers on
+// every instance write to both collections so changes made during populate are not
+// lost. Searches keep using the old collection until the alias swap, which is atomic.
+// It returns the name of the new collection.
+func MigrateTransactionsCollection(ctx context.Context, dimension uint64, populate func(ctx context.Context, collection string) error) (string, error) {
+	if QdrantClient == nil {
+		return "", fmt.Errorf("QdrantClient is not initialized")
+	}
+
+	current, aliased, err := resolveAlias(ctx, TransactionsCollection)
+	if err != nil {
+		return "", err
+	}
+	if !aliased {
+		return "", fmt.Errorf("alias %s does not exist, restart to create it before migrating", TransactionsCollection)
+	}
+
+	next := versionedCollectionName(collectionVersion(current) + 1)
+
+	// A collection left behind by a failed migration isn't referenced by the alias
+	if err := deleteStaleCollection(ctx, next); err != nil {
+		return "", err
+	}
+
+	if err := createTransactionsCollection(ctx, next, dimension, TransactionsDistance); err != nil {
+		return "", err
+	}
+	if err := ensurePayloadIndexes(ctx, next); err != nil {
+		return "", err
+	}
+
+	// Start double-writing before populate reads anything, so nothing changed while it
+	// runs can be missed
+	err = QdrantClient.UpdateAliases(ctx, []*qdrant.AliasOperations{
+		qdrant.NewAliasDelete(MigrationCollection),
+		qdrant.NewAliasCreate(MigrationCollection, next),
+	})
+	if err != nil {
+		abandonMigration(ctx, next)
+		return "", fmt.Errorf("failed to create alias %s: %w", MigrationCollection, err)
+	}
+
+	if err := populate(ctx, next); err != nil {
+		abandonMigration(ctx, next)
+		return "", fmt.Errorf("failed to populate collection %s: %w", next, err)
+	}
+
+	err = QdrantClient.UpdateAliases(ctx, []*qdrant.AliasOperations{
+		qdrant.NewAliasDelete(MigrationCollection),
+		qdrant.NewAliasDelete(TransactionsCollection),
+		qdrant.NewAliasCreate(TransactionsCollection, next),
+	})
+	if err != nil {
+		abandonMigration(ctx, next)
+		return "", fmt.Errorf("failed to swap alias %s to %s: %w", TransactionsCollection, next, err)
+	}
+
+	logger.Get().Info("transactions collection migrated",
+		zap.String("from", current),
+		zap.String("to", next))
+
+	if err := QdrantClient.DeleteCollection(ctx, current); err != nil {
+		logger.Get().Error("failed to delete previous transactions collection",
+			zap.String("collection", current),
+			zap.Error(err))
+	}
+
+	return next, nil
+}
+
+// writeTargets lists the collections a write to collection must reach. Writes
+// through the TransactionsCollection alias are also sent to the collection a running
+// migration is populating, so it doesn't miss changes made while it fills.
+func writeTargets(ctx context.Context, collection string) ([]string, error) {
+	if collection != TransactionsCollection {
+		return []string{collection}, nil
+	}
+
+	_, migrating, err := resolveAlias(ctx, MigrationCollection)
+	if err != nil {
+		return nil, err
+	}
+	if migrating {
+		return []string{collection, MigrationCollection}, nil
+	}
+	return []string{collection}, nil
+}
+
+// abandonMigration stops double-writing and deletes the half-populated collection
+func abandonMigration(ctx context.Context, collection string) {
+	if _, migrating, err := resolveAlias(ctx, MigrationCollection); err == nil && migrating {
+		if err := QdrantClient.DeleteAlias(ctx, MigrationCollection); err != nil {
+			logger.Get().Error("failed to delete migration alias",
+				zap.String("alias", MigrationCollection),
+				zap.Error(err))
+		}
+	}
+	if err := QdrantClient.DeleteCollection(ctx, collection); err != nil {
+		logger.Get().Error("failed to clean up collection after failed migration",
+			zap.String("collection", collection),
+			zap.Error(err))
+	}
+}
+
+// deleteStaleCollection deletes a collection left behind by an earlier failed attempt
+func deleteStaleCollection(ctx context.Context, name string) error {
+	exists, err := QdrantClient.CollectionExists(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to check for collection %s: %w", name, err)
+	}
+	if !exists {
+		return nil
+	}
+	if err := QdrantClient.DeleteCollection(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete stale collection %s: %w", name, err)
+	}
+	return nil
+}
+
+// resolveAlias returns the collection an alias points to and whether the alias exists
+func resolveAlias(ctx context.Context, alias string) (string, bool, error) {
+	aliases, err := QdrantClient.ListAliases(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to list aliases: %w", err)
+	}
+	for _, description := range aliases {
+		if description.GetAliasName() == alias {
+			return description.GetCollectionName(), true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func versionedCollectionName(version int) string {
+	return fmt.Sprintf("%s_v%d", TransactionsCollection, version)
+}
+
+// collectionVersion parses the version from a versioned collection name. The
+// unversioned collection counts as version 0.
+func collectionVersion(name string) int {
+	suffix, ok := strings.CutPrefix(name, TransactionsCollection+"_v")
+	if !ok {
+		return 0
+	}
+	version, err := strconv.Atoi(suffix)
+	if err != nil {
+		return 0
+	}
+	return version
+}
+
+func createTransactionsCollection(ctx context.Context, name string, dimension uint64, distance qdrant.Distance) error {
+	err := QdrantClient.CreateCollection(ctx, &qdrant.CreateCollection{
+		CollectionName: name,
+		VectorsConfig: qdrant.NewVectorsConfig(&qdrant.VectorParams{
+			Size:     dimension,
+			Distance: distance,
+		}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create collection %s: %w", name, err)
+	}
+	return nil
+}
+
+func verifyCollection(ctx context.Context, name string, dimension uint64) error {
+	info, err := QdrantClient.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get collection %s: %w", name, err)
+	}
+
+	params := info.GetConfig().GetParams().GetVectorsConfig().GetParams()
+	if params == nil {
+		return fmt.Errorf("%w: %s has no default vector", ErrSchemaMismatch, name)
+	}
+	if params.GetSize() != dimension {
+		return fmt.Errorf("%w: %s has dimension %d, embedder produces %d", ErrSchemaMismatch, name, params.GetSize(), dimension)
+	}
+	if params.GetDistance() != TransactionsDistance {
+		return fmt.Errorf("%w: %s uses %s distance, expected %s", ErrSchemaMismatch, name, params.GetDistance(), TransactionsDistance)
+	}
+	return nil
+}
+
+// ensurePayloadIndexes creates any keyword indexes the collection is missing
+func ensurePayloadIndexes(ctx context.Context, name string) error {
+	info, err := QdrantClient.GetCollectionInfo(ctx, name)
+	if err != nil {
+		return fmt.Errorf("failed to get collection %s: %w", name, err)
+	}
+	schema := info.GetPayloadSchema()
+
+	wait := true
+	for _, field := range transactionPayloadIndexes {
+		if _, ok := schema[field]; ok {
+			continue
+		}
+		_, err := QdrantClient.CreateFieldIndex(ctx, &qdrant.CreateFieldIndexCollection{
+			CollectionName: name,
+			FieldName:      field,
+			FieldType:      qdrant.FieldType_FieldTypeKeyword.Enum(),
+			Wait:           &wait,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to create payload index %s on %s: %w", field, name, err)
+		}
+		logger.Get().Info("created payload index",
+			zap.String("collection", name),
+			zap.String("field", field))
+	}
+	return nil
+}
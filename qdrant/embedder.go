@@ -0,0 +1,107 @@
+package qdrant
+
+import (
+	"context"
+	"finance-chatbot/api/llm"
+	"finance-chatbot/api/logger"
+	"hash/fnv"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"go.uber.org/zap"
+)
+
+// Embedder turns texts into vectors of a fixed dimension
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+	Dimension() int
+}
+
+// LLMEmbedder embeds texts with the LLM provider's embedding model
+type LLMEmbedder struct {
+	// Dim is the dimension of the configured embedding model
+	Dim int
+}
+
+// DefaultEmbeddingDimension is the dimension of text-embedding-3-small
+const DefaultEmbeddingDimension = 1536
+
+// NewLLMEmbedder returns an embedder for the configured model. EMBEDDING_DIMENSION
+// must be set alongside EMBEDDING_MODEL when the model isn't 1536-dimensional.
+func NewLLMEmbedder() *LLMEmbedder {
+	dim := DefaultEmbeddingDimension
+	if value := os.Getenv("EMBEDDING_DIMENSION"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			dim = parsed
+		} else {
+			logger.Get().Warn("invalid EMBEDDING_DIMENSION, using default",
+				zap.String("value", value),
+				zap.Int("default", DefaultEmbeddingDimension))
+		}
+	}
+	return &LLMEmbedder{Dim: dim}
+}
+
+func (e *LLMEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return llm.CreateEmbeddings(texts)
+}
+
+func (e *LLMEmbedder) Dimension() int {
+	return e.Dim
+}
+
+// HashEmbedder is a deterministic embedder for tests and local development. Each
+// token is hashed into one of Dim buckets and the result is normalised, so texts
+// sharing words end up close together without calling an external service.
+type HashEmbedder struct {
+	Dim int
+}
+
+func (e *HashEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i, text := range texts {
+		vectors[i] = e.embed(text)
+	}
+	return vectors, nil
+}
+
+func (e *HashEmbedder) Dimension() int {
+	return e.Dim
+}
+
+func (e *HashEmbedder) embed(text string) []float32 {
+	vector := make([]float32, e.Dim)
+	tokens := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	for _, token := range tokens {
+		h := fnv.New64a()
+		h.Write([]byte(token))
+		sum := h.Sum64()
+
+		// The top bit picks the sign so unrelated tokens tend to cancel out
+		bucket := int(sum % uint64(e.Dim))
+		if sum>>63 == 1 {
+			vector[bucket]--
+		} else {
+			vector[bucket]++
+		}
+	}
+
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v) * float64(v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	scale := float32(1 / math.Sqrt(norm))
+	for i := range vector {
+		vector[i] *= scale
+	}
+	return vector
+}
@@ -0,0 +1,288 @@
+package qdrant
+
+import (
+	"context"
+	"finance-chatbot/api/models"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/qdrant/go-client/qdrant"
+)
+
+const (
+	indexBatchSize = 100
+	itemIDField    = "metadata.item_id"
+	accountIDField = "metadata.account_id"
+)
+
+// transactionNamespace derives stable point IDs from Plaid transaction IDs, which
+// Qdrant can't use directly because point IDs must be UUIDs or integers
+var transactionNamespace = uuid.MustParse("0f5b3c7e-6c1d-4a8e-9a57-3d2f1e4b8c90")
+
+// TransactionPointID returns the Qdrant point ID for a Plaid transaction ID
+func TransactionPointID(transactionID string) string {
+	return uuid.NewSHA1(transactionNamespace, []byte(transactionID)).String()
+}
+
+// TransactionText is the canonical text embedded for a transaction
+func TransactionText(t models.Transaction) string {
+	parts := []string{t.Date}
+
+	if t.MerchantName != "" && !strings.EqualFold(t.MerchantName, t.Name) {
+		parts = append(parts, t.MerchantName, t.Name)
+	} else {
+		parts = append(parts, t.Name)
+	}
+
+	// Plaid amounts are positive for money leaving the account
+	if t.Amount < 0 {
+		parts = append(parts, fmt.Sprintf("received %.2f", -t.Amount))
+	} else {
+		parts = append(parts, fmt.Sprintf("spent %.2f", t.Amount))
+	}
+
+	if t.Category != "" {
+		parts = append(parts, strings.ToLower(strings.ReplaceAll(t.Category, "_", " ")))
+	}
+	if t.Pending {
+		parts = append(parts, "pending")
+	}
+
+	return strings.Join(parts, " | ")
+}
+
+// Indexer embeds transactions and keeps their points in the transactions collection
+// in sync
+type Indexer struct {
+	client     *qdrant.Client
+	collection string
+	embedder   Embedder
+}
+
+// NewIndexer returns an Indexer writing to the transactions collection with the
+// shared client
+func NewIndexer(embedder Embedder) *Indexer {
+	return &Indexer{
+		client:     QdrantClient,
+		collection: TransactionsCollection,
+		embedder:   embedder,
+	}
+}
+
+// InCollection returns a copy of the indexer that writes to another collection, such
+// as a new version being populated during a migration
+func (ix *Indexer) InCollection(collection string) *Indexer {
+	copied := *ix
+	copied.collection = collection
+	return &copied
+}
+
+// Dimension is the vector size the indexer's embedder produces
+func (ix *Indexer) Dimension() uint64 {
+	return uint64(ix.embedder.Dimension())
+}
+
+// EmbedQuery embeds a search query with the indexer's embedder so it lands in the
+// same vector space as the indexed transactions
+func (ix *Indexer) EmbedQuery(ctx context.Context, query string) ([]float32, error) {
+	vectors, err := ix.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 || len(vectors[0]) != ix.embedder.Dimension() {
+		return nil, fmt.Errorf("embedder returned an unexpected vector for the query")
+	}
+	return vectors[0], nil
+}
+
+// IndexItem replaces the points for a Plaid item with the given transactions. Every
+// transaction is upserted and points for the item that are no longer in the list are
+// deleted. It returns how many transactions were indexed.
+func (ix *Indexer) IndexItem(ctx context.Context, userID string, itemID string, transactions []models.Transaction) (int, error) {
+	if err := ix.Upsert(ctx, userID, itemID, transactions); err != nil {
+		return 0, err
+	}
+
+	keep := make([]*qdrant.PointId, 0, len(transactions))
+	for _, t := range transactions {
+		keep = append(keep, qdrant.NewIDUUID(TransactionPointID(t.TransactionID)))
+	}
+
+	filter := &qdrant.Filter{
+		Must: []*qdrant.Condition{
+			qdrant.NewMatch(userIDField, userID),
+			qdrant.NewMatch(itemIDField, itemID),
+		},
+	}
+	if len(keep) > 0 {
+		filter.MustNot = []*qdrant.Condition{qdrant.NewHasID(keep...)}
+	}
+
+	collections, err := ix.collections(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	wait := true
+	for _, collection := range collections {
+		_, err := ix.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collection,
+			Points:         qdrant.NewPointsSelectorFilter(filter),
+			Wait:           &wait,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete stale transactions for item %s from %s: %w", itemID, collection, err)
+		}
+	}
+
+	return len(transactions), nil
+}
+
+// Upsert embeds and writes points for the transactions, overwriting any existing
+// points for the same transaction IDs
+func (ix *Indexer) Upsert(ctx context.Context, userID string, itemID string, transactions []models.Transaction) error {
+	if ix.client == nil {
+		return fmt.Errorf("QdrantClient is not initialized")
+	}
+
+	collections, err := ix.collections(ctx)
+	if err != nil {
+		return err
+	}
+
+	for start := 0; start < len(transactions); start += indexBatchSize {
+		batch := transactions[start:min(start+indexBatchSize, len(transactions))]
+
+		texts := make([]string, len(batch))
+		for i, t := range batch {
+			texts[i] = TransactionText(t)
+		}
+
+		vectors, err := ix.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed transactions: %w", err)
+		}
+
+		points, err := ix.transactionPoints(userID, itemID, batch, texts, vectors)
+		if err != nil {
+			return err
+		}
+
+		wait := true
+		for _, collection := range collections {
+			_, err = ix.client.Upsert(ctx, &qdrant.UpsertPoints{
+				CollectionName: collection,
+				Points:         points,
+				Wait:           &wait,
+			})
+			if err != nil {
+				return fmt.Errorf("failed to upsert transactions to %s: %w", collection, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// transactionPoints builds the points for a batch of transactions from their
+// canonical texts and embeddings
+func (ix *Indexer) transactionPoints(userID string, itemID string, transactions []models.Transaction, texts []string, vectors [][]float32) ([]*qdrant.PointStruct, error) {
+	if len(vectors) != len(transactions) {
+		return nil, fmt.Errorf("embedder returned %d vectors for %d transactions", len(vectors), len(transactions))
+	}
+
+	points := make([]*qdrant.PointStruct, len(transactions))
+	for i, t := range transactions {
+		if len(vectors[i]) != ix.embedder.Dimension() {
+			return nil, fmt.Errorf("embedding has dimension %d, expected %d", len(vectors[i]), ix.embedder.Dimension())
+		}
+
+		points[i] = &qdrant.PointStruct{
+			Id:      qdrant.NewIDUUID(TransactionPointID(t.TransactionID)),
+			Vectors: qdrant.NewVectors(vectors[i]...),
+			Payload: qdrant.NewValueMap(map[string]any{
+				payloadPageContent: texts[i],
+				payloadMetadata: map[string]any{
+					"user_id":        userID,
+					"item_id":        itemID,
+					"account_id":     t.AccountID,
+					"transaction_id": t.TransactionID,
+					"date":           t.Date,
+					"amount":         t.Amount,
+					"name":           t.Name,
+					"merchant_name":  t.MerchantName,
+					"category":       t.Category,
+					"pending":        t.Pending,
+				},
+			}),
+		}
+	}
+
+	return points, nil
+}
+
+// Remove deletes the points for transactions Plaid reported as removed
+func (ix *Indexer) Remove(ctx context.Context, transactionIDs []string) error {
+	if ix.client == nil {
+		return fmt.Errorf("QdrantClient is not initialized")
+	}
+	if len(transactionIDs) == 0 {
+		return nil
+	}
+
+	ids := make([]*qdrant.PointId, len(transactionIDs))
+	for i, id := range transactionIDs {
+		ids[i] = qdrant.NewIDUUID(TransactionPointID(id))
+	}
+
+	collections, err := ix.collections(ctx)
+	if err != nil {
+		return err
+	}
+
+	wait := true
+	for _, collection := range collections {
+		_, err := ix.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collection,
+			Points:         qdrant.NewPointsSelectorIDs(ids),
+			Wait:           &wait,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete removed transactions from %s: %w", collection, err)
+		}
+	}
+	return nil
+}
+
+// RemoveItem deletes every point for a Plaid item
+func (ix *Indexer) RemoveItem(ctx context.Context, itemID string) error {
+	if ix.client == nil {
+		return fmt.Errorf("QdrantClient is not initialized")
+	}
+
+	collections, err := ix.collections(ctx)
+	if err != nil {
+		return err
+	}
+
+	wait := true
+	for _, collection := range collections {
+		_, err := ix.client.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collection,
+			Points: qdrant.NewPointsSelectorFilter(&qdrant.Filter{
+				Must: []*qdrant.Condition{qdrant.NewMatch(itemIDField, itemID)},
+			}),
+			Wait: &wait,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete transactions for item %s from %s: %w", itemID, collection, err)
+		}
+	}
+	return nil
+}
+
+// collections lists where the indexer's writes go
+func (ix *Indexer) collections(ctx context.Context) ([]string, error) {
+	return writeTargets(ctx, ix.collection)
+}
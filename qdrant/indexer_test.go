@@ -0,0 +1,202 @@
+package qdrant
+
+import (
+	"context"
+	"finance-chatbot/api/models"
+	"math"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+const testDimension = 64
+
+func TestTransactionPointID(t *testing.T) {
+	id := TransactionPointID("txn-1")
+
+	if _, err := uuid.Parse(id); err != nil {
+		t.Fatalf("point ID %q is not a UUID: %v", id, err)
+	}
+	if again := TransactionPointID("txn-1"); again != id {
+		t.Errorf("point ID changed between calls: %s then %s", id, again)
+	}
+	if other := TransactionPointID("txn-2"); other == id {
+		t.Errorf("different transactions share point ID %s", id)
+	}
+}
+
+func TestTransactionText(t *testing.T) {
+	tests := []struct {
+		name        string
+		transaction models.Transaction
+		want        string
+	}{
+		{
+			name:        "spending",
+			transaction: models.Transaction{Date: "2024-03-01", Name: "Coffee Shop", Amount: 4.5},
+			want:        "2024-03-01 | Coffee Shop | spent 4.50",
+		},
+		{
+			name:        "income",
+			transaction: models.Transaction{Date: "2024-03-01", Name: "Payroll", Amount: -2500},
+			want:        "2024-03-01 | Payroll | received 2500.00",
+		},
+		{
+			name: "merchant differs from name",
+			transaction: models.Transaction{
+				Date:         "2024-03-02",
+				Name:         "SQ *BLUE BOTTLE 1234",
+				MerchantName: "Blue Bottle",
+				Amount:       6,
+				Category:     "FOOD_AND_DRINK",
+				Pending:      true,
+			},
+			want: "2024-03-02 | Blue Bottle | SQ *BLUE BOTTLE 1234 | spent 6.00 | food and drink | pending",
+		},
+		{
+			name:        "merchant matches name",
+			transaction: models.Transaction{Date: "2024-03-03", Name: "Netflix", MerchantName: "NETFLIX", Amount: 15.49},
+			want:        "2024-03-03 | Netflix | spent 15.49",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TransactionText(tt.transaction); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransactionPoints(t *testing.T) {
+	embedder := &HashEmbedder{Dim: testDimension}
+	ix := &Indexer{collection: TransactionsCollection, embedder: embedder}
+
+	transactions := []models.Transaction{
+		{TransactionID: "txn-1", AccountID: "acct-1", Date: "2024-03-01", Name: "Coffee Shop", Amount: 4.5, Category: "FOOD_AND_DRINK"},
+		{TransactionID: "txn-2", AccountID: "acct-2", Date: "2024-03-02", Name: "Payroll", Amount: -2500, Pending: true},
+	}
+	texts := []string{TransactionText(transactions[0]), TransactionText(transactions[1])}
+	vectors, err := embedder.Embed(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("embedding: %v", err)
+	}
+
+	points, err := ix.transactionPoints("user-1", "item-1", transactions, texts, vectors)
+	if err != nil {
+		t.Fatalf("building points: %v", err)
+	}
+	if len(points) != len(transactions) {
+		t.Fatalf("got %d points, want %d", len(points), len(transactions))
+	}
+
+	for i, point := range points {
+		transaction := transactions[i]
+
+		if got, want := point.GetId().GetUuid(), TransactionPointID(transaction.TransactionID); got != want {
+			t.Errorf("point %d: ID %s, want %s", i, got, want)
+		}
+		if got := len(point.GetVectors().GetVector().GetData()); got != testDimension {
+			t.Errorf("point %d: vector dimension %d, want %d", i, got, testDimension)
+		}
+		if got := point.Payload[payloadPageContent].GetStringValue(); got != texts[i] {
+			t.Errorf("point %d: page content %q, want %q", i, got, texts[i])
+		}
+
+		metadata := point.Payload[payloadMetadata].GetStructValue().GetFields()
+		for field, want := range map[string]string{
+			"user_id":        "user-1",
+			"item_id":        "item-1",
+			"account_id":     transaction.AccountID,
+			"transaction_id": transaction.TransactionID,
+			"date":           transaction.Date,
+			"name":           transaction.Name,
+			"category":       transaction.Category,
+		} {
+			if got := metadata[field].GetStringValue(); got != want {
+				t.Errorf("point %d: metadata %s = %q, want %q", i, field, got, want)
+			}
+		}
+		if got := metadata["amount"].GetDoubleValue(); got != transaction.Amount {
+			t.Errorf("point %d: metadata amount = %v, want %v", i, got, transaction.Amount)
+		}
+		if got := metadata["pending"].GetBoolValue(); got != transaction.Pending {
+			t.Errorf("point %d: metadata pending = %v, want %v", i, got, transaction.Pending)
+		}
+	}
+}
+
+func TestTransactionPointsRejectsBadEmbeddings(t *testing.T) {
+	ix := &Indexer{collection: TransactionsCollection, embedder: &HashEmbedder{Dim: testDimension}}
+	transactions := []models.Transaction{{TransactionID: "txn-1", Name: "Coffee Shop"}}
+	texts := []string{TransactionText(transactions[0])}
+
+	if _, err := ix.transactionPoints("user-1", "item-1", transactions, texts, nil); err == nil {
+		t.Error("missing vectors were accepted")
+	}
+
+	wrongDimension := [][]float32{make([]float32, testDimension/2)}
+	if _, err := ix.transactionPoints("user-1", "item-1", transactions, texts, wrongDimension); err == nil {
+		t.Error("vector with the wrong dimension was accepted")
+	}
+}
+
+func TestEmbedQueryMatchesIndexedText(t *testing.T) {
+	embedder := &HashEmbedder{Dim: testDimension}
+	ix := NewIndexer(embedder)
+
+	query, err := ix.EmbedQuery(context.Background(), "coffee shop")
+	if err != nil {
+		t.Fatalf("embedding query: %v", err)
+	}
+	indexed, _ := embedder.Embed(context.Background(), []string{"coffee shop"})
+	if cosine(query, indexed[0]) != 1 {
+		t.Error("query was not embedded with the indexer's embedder")
+	}
+}
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := &HashEmbedder{Dim: testDimension}
+	vectors, err := embedder.Embed(context.Background(), []string{
+		"coffee shop latte",
+		"coffee shop espresso",
+		"mortgage payment",
+		"",
+	})
+	if err != nil {
+		t.Fatalf("embedding: %v", err)
+	}
+
+	again, _ := embedder.Embed(context.Background(), []string{"coffee shop latte"})
+	if cosine(vectors[0], again[0]) != 1 {
+		t.Error("embedding the same text twice gave different vectors")
+	}
+
+	for i, vector := range vectors[:3] {
+		if norm := math.Sqrt(cosineDot(vector, vector)); math.Abs(norm-1) > 1e-6 {
+			t.Errorf("vector %d has norm %v, want 1", i, norm)
+		}
+	}
+	if norm := cosineDot(vectors[3], vectors[3]); norm != 0 {
+		t.Errorf("empty text has norm %v, want 0", norm)
+	}
+
+	if related, unrelated := cosine(vectors[0], vectors[1]), cosine(vectors[0], vectors[2]); related <= unrelated {
+		t.Errorf("texts sharing words scored %v, unrelated texts %v", related, unrelated)
+	}
+}
+
+func cosineDot(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// cosine is the dot product of two normalised vectors, rounded so identical
+// vectors compare equal
+func cosine(a, b []float32) float64 {
+	return math.Round(cosineDot(a, b)*1e6) / 1e6
+}
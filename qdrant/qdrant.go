@@ -3,6 +3,7 @@ package qdrant
 import (
 	"fmt"
 	"os"
+	"strconv"
 
 	"finance-chatbot/api/logger"
 
@@ -13,38 +14,62 @@ import (
 var (
 	QdrantClient           *qdrant.Client
 	TransactionsCollection = "transactions"
+	// MigrationCollection is an alias for the collection a running migration is
+	// populating. It only exists during a migration.
+	MigrationCollection = TransactionsCollection + "_next"
 )
 
-// InitQdrantClient initializes the Qdrant Cloud client.
+// InitQdrantClient initializes the Qdrant client. QDRANT_PORT defaults to 6334, the
+// gRPC port, and QDRANT_USE_TLS defaults to true for Qdrant Cloud. QDRANT_API_KEY is
+// optional so a plaintext local Qdrant can be used in development.
 func InitQdrantClient() error {
 	host := os.Getenv("QDRANT_URL")
 	if host == "" {
-		return fmt.Errorf("QDRANT_HOST environment variable not set")
+		return fmt.Errorf("QDRANT_URL environment variable not set")
 	}
 
-	apiKey := os.Getenv("QDRANT_API_KEY")
-	if apiKey == "" {
-		return fmt.Errorf("QDRANT_API_KEY environment variable not set")
+	port := 6334
+	if value := os.Getenv("QDRANT_PORT"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid QDRANT_PORT %q: %w", value, err)
+		}
+		port = parsed
+	}
+
+	useTLS := true
+	if value := os.Getenv("QDRANT_USE_TLS"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid QDRANT_USE_TLS %q: %w", value, err)
+		}
+		useTLS = parsed
 	}
 
-	port := 6334 // Default secure gRPC port for Qdrant Cloud
+	apiKey := os.Getenv("QDRANT_API_KEY")
+	if apiKey == "" && useTLS {
+		logger.Get().Warn("QDRANT_API_KEY not set for a TLS connection")
+	}
 
 	client, err := qdrant.NewClient(&qdrant.Config{
 		Host:   host,
 		Port:   port,
 		APIKey: apiKey,
-		UseTLS: true,
+		UseTLS: useTLS,
 	})
 	if err != nil {
-		logger.Get().Error("failed to connect to Qdrant Cloud",
+		logger.Get().Error("failed to connect to Qdrant",
 			zap.String("host", host),
+			zap.Int("port", port),
 			zap.Error(err))
 		return fmt.Errorf("failed to create Qdrant client: %w", err)
 	}
 
 	QdrantClient = client
-	logger.Get().Info("successfully connected to Qdrant Cloud",
-		zap.String("host", host))
+	logger.Get().Info("successfully connected to Qdrant",
+		zap.String("host", host),
+		zap.Int("port", port),
+		zap.Bool("tls", useTLS))
 
 	return nil
 }
@@ -2,44 +2,132 @@ package qdrant
 
 import (
 	"context"
+	"finance-chatbot/api/models"
 	"fmt"
 
 	"github.com/qdrant/go-client/qdrant"
 )
 
+// Transactions are stored in the LangChain layout: the embedded text is in
+// page_content and the transaction fields are nested under metadata
+const (
+	payloadPageContent = "page_content"
+	payloadMetadata    = "metadata"
+	userIDField        = "metadata.user_id"
+)
+
+// SearchTransactions returns the user's transactions nearest to vector, best match first.
+// Results scoring below minScore are dropped; a minScore of 0 keeps everything.
+func SearchTransactions(ctx context.Context, userID string, vector []float32, limit uint64, minScore float32) ([]models.TransactionSearchResult, error) {
+	if QdrantClient == nil {
+		return nil, fmt.Errorf("QdrantClient is not initialized")
+	}
+
+	request := &qdrant.QueryPoints{
+		CollectionName: TransactionsCollection,
+		Query:          qdrant.NewQuery(vector...),
+		Filter: &qdrant.Filter{
+			Must: []*qdrant.Condition{qdrant.NewMatch(userIDField, userID)},
+		},
+		Limit:       &limit,
+		WithPayload: qdrant.NewWithPayload(true),
+	}
+	if minScore > 0 {
+		request.ScoreThreshold = &minScore
+	}
+
+	points, err := QdrantClient.Query(ctx, request)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transactions for user_id %s: %w", userID, err)
+	}
+
+	results := make([]models.TransactionSearchResult, 0, len(points))
+	for _, point := range points {
+		payload := point.GetPayload()
+		metadata := payload[payloadMetadata].GetStructValue().GetFields()
+
+		results = append(results, models.TransactionSearchResult{
+			Transaction: models.Transaction{
+				TransactionID: metadata["transaction_id"].GetStringValue(),
+				AccountID:     metadata["account_id"].GetStringValue(),
+				Date:          metadata["date"].GetStringValue(),
+				Amount:        numberValue(metadata["amount"]),
+				Name:          metadata["name"].GetStringValue(),
+				MerchantName:  metadata["merchant_name"].GetStringValue(),
+				Category:      metadata["category"].GetStringValue(),
+				Pending:       metadata["pending"].GetBoolValue(),
+			},
+			Text:  payload[payloadPageContent].GetStringValue(),
+			Score: point.GetScore(),
+		})
+	}
+
+	return results, nil
+}
+
+// numberValue reads a payload number, which is stored as an integer when it has no
+// fractional part
+func numberValue(value *qdrant.Value) float64 {
+	if _, ok := value.GetKind().(*qdrant.Value_IntegerValue); ok {
+		return float64(value.GetIntegerValue())
+	}
+	return value.GetDoubleValue()
+}
+
 // DeleteTransactionsByUserID deletes all transactions from the "transactions" collection
-// that have metadata field "user_id" equal to the given userId.
+// that have metadata field "user_id" equal to the given userId. It waits until the
+// points are gone, so they are no longer searchable once it returns.
 func DeleteTransactionsByUserID(userId string) error {
+	if err := deleteTransactionsMatching(context.Background(), userIDField, userId); err != nil {
+		return fmt.Errorf("failed to delete transactions for user_id %s: %w", userId, err)
+	}
+	return nil
+}
+
+// DeleteTransactionsByItemID deletes every transaction belonging to a Plaid item and
+// waits for the delete to complete
+func DeleteTransactionsByItemID(ctx context.Context, itemID string) error {
+	if err := deleteTransactionsMatching(ctx, itemIDField, itemID); err != nil {
+		return fmt.Errorf("failed to delete transactions for item_id %s: %w", itemID, err)
+	}
+	return nil
+}
+
+// DeleteTransactionsByAccountID deletes every transaction belonging to an account and
+// waits for the delete to complete
+func DeleteTransactionsByAccountID(ctx context.Context, accountID string) error {
+	if err := deleteTransactionsMatching(ctx, accountIDField, accountID); err != nil {
+		return fmt.Errorf("failed to delete transactions for account_id %s: %w", accountID, err)
+	}
+	return nil
+}
+
+func deleteTransactionsMatching(ctx context.Context, field string, value string) error {
 	if QdrantClient == nil {
 		return fmt.Errorf("QdrantClient is not initialized")
 	}
 
 	filter := &qdrant.Filter{
-		Must: []*qdrant.Condition{
-			{
-				ConditionOneOf: &qdrant.Condition_Field{
-					Field: &qdrant.FieldCondition{
-						Key: "metadata.user_id",
-						Match: &qdrant.Match{
-							MatchValue: &qdrant.Match_Keyword{
-								Keyword: userId,
-							},
-						},
-					},
-				},
-			},
-		},
+		Must: []*qdrant.Condition{qdrant.NewMatch(field, value)},
 	}
 
-	waitBeforeReturning := false
-	_, err := QdrantClient.Delete(context.Background(), &qdrant.DeletePoints{
-		CollectionName: TransactionsCollection,
-		Points:         qdrant.NewPointsSelectorFilter(filter),
-		Wait:           &waitBeforeReturning,
-	})
+	// A migration in progress gets the delete too, or the points would come back
+	// once it swaps in
+	collections, err := writeTargets(ctx, TransactionsCollection)
 	if err != nil {
-		return fmt.Errorf("failed to delete transactions for user_id %s: %w", userId, err)
+		return err
 	}
 
+	waitBeforeReturning := true
+	for _, collection := range collections {
+		_, err := QdrantClient.Delete(ctx, &qdrant.DeletePoints{
+			CollectionName: collection,
+			Points:         qdrant.NewPointsSelectorFilter(filter),
+			Wait:           &waitBeforeReturning,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to delete from %s: %w", collection, err)
+		}
+	}
 	return nil
 }
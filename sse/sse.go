@@ -9,8 +9,24 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// EventSuggestions carries follow-up prompts for the answer that just finished
+	EventSuggestions = "suggestions"
+	// EventCancelled ends an answer the user cancelled in place of [DONE]
+	EventCancelled = "cancelled"
+)
+
+// Event is one server-sent event. Chunks of an answer and the [DONE] and [ERROR]
+// markers use the default unnamed event with a Message; named events carry their own
+// Data.
+type Event struct {
+	Name    string
+	Message string
+	Data    any
+}
+
 type ClientStream struct {
-	Messages      chan string
+	Messages      chan Event
 	BufferFlushed chan struct{} // closed once buffer is flushed
 	CloseOnce     sync.Once
 }
@@ -34,7 +50,7 @@ func RegisterClient(conversationID string, stream *ClientStream) {
 			var aiResponse models.AIResponse
 			if err := json.Unmarshal([]byte(chunk), &aiResponse); err == nil {
 				message := resolveMessage(aiResponse)
-				stream.Messages <- message
+				stream.Messages <- Event{Message: message}
 			} else {
 				logger.Get().Error("Failed to unmarshal buffered chunk",
 					zap.Error(err),
@@ -84,7 +100,7 @@ func SendChunkToClient(conversationID string, chunk string) {
 	message := resolveMessage(aiResponse)
 
 	select {
-	case clientStream.Messages <- message:
+	case clientStream.Messages <- Event{Message: message}:
 		logger.Get().Debug("Sent message to client",
 			zap.String("message", message),
 			zap.String("conversationID", conversationID))
@@ -94,6 +110,47 @@ func SendChunkToClient(conversationID string, chunk string) {
 	}
 }
 
+type cancelledEvent struct {
+	GenerationID string `json:"generation_id"`
+}
+
+// CancelGeneration drops any buffered chunks for the conversation and sends the
+// client a terminal cancelled event
+func CancelGeneration(conversationID string, generationID string) {
+	Mu.Lock()
+	delete(BufferedChunks, conversationID)
+	Mu.Unlock()
+
+	SendEvent(conversationID, EventCancelled, cancelledEvent{GenerationID: generationID})
+}
+
+// SendEvent sends a named event to the conversation's client. Unlike answer chunks,
+// events are not buffered; they are dropped when no client is connected.
+func SendEvent(conversationID string, name string, data any) {
+	Mu.RLock()
+	clientStream, ok := SSEConnections[conversationID]
+	Mu.RUnlock()
+
+	if !ok {
+		logger.Get().Debug("Dropped event because client not connected",
+			zap.String("event", name),
+			zap.String("conversationID", conversationID))
+		return
+	}
+
+	<-clientStream.BufferFlushed
+
+	select {
+	case clientStream.Messages <- Event{Name: name, Data: data}:
+		logger.Get().Debug("Sent event to client",
+			zap.String("event", name),
+			zap.String("conversationID", conversationID))
+	default:
+		logger.Get().Warn("Client message channel is blocked",
+			zap.String("conversationID", conversationID))
+	}
+}
+
 // UnregisterClient cleans up client resources
 func UnregisterClient(conversationID string) {
 	Mu.Lock()
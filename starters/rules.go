@@ -0,0 +1,188 @@
+package starters
+
+import (
+	"finance-chatbot/api/models"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Account types as reported by Plaid
+const (
+	accountTypeCredit     = "credit"
+	accountTypeDepository = "depository"
+)
+
+// creditUtilizationRule flags credit accounts whose balance is a large share of the limit
+type creditUtilizationRule struct{}
+
+const creditUtilizationThreshold = 0.3
+
+func (creditUtilizationRule) Name() string { return "credit_utilization" }
+
+func (creditUtilizationRule) Evaluate(input Input) []Starter {
+	var starters []Starter
+	for _, account := range input.Accounts {
+		if account.Type != accountTypeCredit || account.Balances.Limit == nil || *account.Balances.Limit <= 0 {
+			continue
+		}
+
+		utilization := account.Balances.Current / *account.Balances.Limit
+		if utilization < creditUtilizationThreshold {
+			continue
+		}
+
+		starters = append(starters, Starter{
+			Prompt: fmt.Sprintf("Your %s utilization is %.0f%% — want a payoff plan?", accountLabel(account.Name, "credit card"), utilization*100),
+			Score:  math.Min(utilization, 1),
+		})
+	}
+	return starters
+}
+
+// overspendingRule fires when recent spending is above the user's monthly income
+type overspendingRule struct{}
+
+func (overspendingRule) Name() string { return "overspending" }
+
+func (overspendingRule) Evaluate(input Input) []Starter {
+	if input.UserInfo == nil || input.UserInfo.Income <= 0 {
+		return nil
+	}
+
+	spent := totalSpending(input)
+	if spent <= input.UserInfo.Income {
+		return nil
+	}
+
+	over := spent/input.UserInfo.Income - 1
+	return []Starter{{
+		Prompt: fmt.Sprintf("You've spent $%.0f in the last 30 days, more than your monthly income — want help building a budget?", spent),
+		Score:  math.Min(0.7+over, 0.95),
+	}}
+}
+
+// topCategoryRule points at the category that took the largest share of recent spending
+type topCategoryRule struct{}
+
+const topCategoryMinShare = 0.25
+
+func (topCategoryRule) Name() string { return "top_category" }
+
+func (topCategoryRule) Evaluate(input Input) []Starter {
+	byCategory := map[string]float64{}
+	var total float64
+	for _, transaction := range input.Transactions {
+		if !isSpending(transaction) || transaction.Category == "" {
+			continue
+		}
+		byCategory[transaction.Category] += transaction.Amount
+		total += transaction.Amount
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var topCategory string
+	var topAmount float64
+	for category, amount := range byCategory {
+		if amount > topAmount || (amount == topAmount && category < topCategory) {
+			topCategory, topAmount = category, amount
+		}
+	}
+
+	share := topAmount / total
+	if share < topCategoryMinShare {
+		return nil
+	}
+
+	return []Starter{{
+		Prompt: fmt.Sprintf("%.0f%% of your spending in the last 30 days went to %s — want ideas to cut back?", share*100, categoryLabel(topCategory)),
+		Score:  0.4 + share/2,
+	}}
+}
+
+// savingsGoalRule offers to plan toward the savings goal in the user's profile
+type savingsGoalRule struct{}
+
+func (savingsGoalRule) Name() string { return "savings_goal" }
+
+func (savingsGoalRule) Evaluate(input Input) []Starter {
+	if input.UserInfo == nil || input.UserInfo.SavingsGoal <= 0 {
+		return nil
+	}
+
+	var cash float64
+	for _, account := range input.Accounts {
+		if account.Type == accountTypeDepository {
+			cash += account.Balances.Current
+		}
+	}
+
+	goal := input.UserInfo.SavingsGoal
+	if cash >= goal {
+		return []Starter{{
+			Prompt: fmt.Sprintf("You've reached your $%.0f savings goal — what should I do with the extra cash?", goal),
+			Score:  0.5,
+		}}
+	}
+
+	return []Starter{{
+		Prompt: fmt.Sprintf("How can I close the $%.0f gap to my savings goal?", goal-cash),
+		Score:  0.45,
+	}}
+}
+
+// generalRule always offers a couple of prompts so the list is never empty
+type generalRule struct{}
+
+func (generalRule) Name() string { return "general" }
+
+func (generalRule) Evaluate(input Input) []Starter {
+	if len(input.Accounts) == 0 {
+		return []Starter{
+			{Prompt: "How do I start building a monthly budget?", Score: 0.2},
+			{Prompt: "What should I look for in a high-yield savings account?", Score: 0.1},
+		}
+	}
+	return []Starter{
+		{Prompt: "Give me a quick overview of my finances", Score: 0.2},
+		{Prompt: "Where is most of my money going?", Score: 0.1},
+	}
+}
+
+// nonSpendingCategories are outflows that move money rather than spend it
+var nonSpendingCategories = map[string]bool{
+	"INCOME":        true,
+	"TRANSFER_IN":   true,
+	"TRANSFER_OUT":  true,
+	"LOAN_PAYMENTS": true,
+}
+
+// isSpending reports whether a transaction is money spent. Plaid reports money
+// leaving an account as a positive amount.
+func isSpending(transaction models.Transaction) bool {
+	return transaction.Amount > 0 && !transaction.Pending && !nonSpendingCategories[transaction.Category]
+}
+
+func totalSpending(input Input) float64 {
+	var total float64
+	for _, transaction := range input.Transactions {
+		if isSpending(transaction) {
+			total += transaction.Amount
+		}
+	}
+	return total
+}
+
+func accountLabel(name string, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	return name
+}
+
+// categoryLabel turns a Plaid category such as FOOD_AND_DRINK into "food and drink"
+func categoryLabel(category string) string {
+	return strings.ToLower(strings.ReplaceAll(category, "_", " "))
+}
@@ -0,0 +1,82 @@
+// Package starters builds personalised prompts for starting a new conversation.
+// Each Rule looks at the user's accounts, profile and recent spending and may
+// propose prompts; Generate ranks everything the registered rules return.
+package starters
+
+import (
+	"finance-chatbot/api/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Input is everything a rule can base its prompts on. UserInfo is nil when the user
+// hasn't filled in their profile; Transactions covers the RecentSpendingWindow.
+type Input struct {
+	Accounts     []models.Account
+	UserInfo     *models.UserInfo
+	Transactions []models.Transaction
+	Now          time.Time
+}
+
+// RecentSpendingWindow is how far back Input.Transactions reaches
+const RecentSpendingWindow = 30 * 24 * time.Hour
+
+// Starter is one suggested prompt. Score is between 0 and 1; higher scores are
+// more relevant and shown first.
+type Starter struct {
+	Rule   string  `json:"rule"`
+	Prompt string  `json:"prompt"`
+	Score  float64 `json:"score"`
+}
+
+// Rule proposes starters from an Input. Rules return nothing when they don't apply.
+type Rule interface {
+	Name() string
+	Evaluate(input Input) []Starter
+}
+
+var (
+	mu    sync.RWMutex
+	rules []Rule
+)
+
+// Register adds a rule to the set used by Generate
+func Register(rule Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	rules = append(rules, rule)
+}
+
+// Generate runs every registered rule and returns up to limit starters, highest score
+// first. Each rule's starters are tagged with its name.
+func Generate(input Input, limit int) []Starter {
+	mu.RLock()
+	registered := append([]Rule(nil), rules...)
+	mu.RUnlock()
+
+	results := []Starter{}
+	for _, rule := range registered {
+		for _, starter := range rule.Evaluate(input) {
+			starter.Rule = rule.Name()
+			results = append(results, starter)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+func init() {
+	Register(creditUtilizationRule{})
+	Register(overspendingRule{})
+	Register(topCategoryRule{})
+	Register(savingsGoalRule{})
+	Register(generalRule{})
+}
@@ -13,6 +13,14 @@ import (
 	"go.uber.org/zap"
 )
 
+const (
+	// cancelledGenerationTTL is how long chunks for a cancelled generation keep being dropped
+	cancelledGenerationTTL = 10 * time.Minute
+	// finishedGenerationTTL is how long a finished generation is remembered, so a
+	// late cancel doesn't end an answer the client already received in full
+	finishedGenerationTTL = 10 * time.Minute
+)
+
 type WorkerPool struct {
 	workers    int
 	partitions []chan []byte
@@ -26,6 +34,15 @@ type WorkerPool struct {
 	processingDuration uint64
 	bufferFillLevels   []uint64
 	messagesDropped    uint64
+
+	// Generation IDs the user cancelled and generation IDs whose final chunk was
+	// forwarded, mapped to when they can be forgotten
+	cancelMu  sync.Mutex
+	cancelled map[string]time.Time
+	finished  map[string]time.Time
+
+	// Called once the final chunk of a successful answer has been forwarded
+	onComplete func(models.AIResponse)
 }
 
 func NewWorkerPool(workers int) *WorkerPool {
@@ -41,9 +58,18 @@ func NewWorkerPool(workers int) *WorkerPool {
 		ctx:              ctx,
 		cancelFunc:       cancel,
 		bufferFillLevels: bufferLevels,
+		cancelled:        make(map[string]time.Time),
+		finished:         make(map[string]time.Time),
 	}
 }
 
+// OnComplete registers fn to run after the final chunk of each successful answer is
+// sent to the client. fn runs on its own goroutine so it never holds up a partition.
+// It must be set before Start.
+func (wp *WorkerPool) OnComplete(fn func(models.AIResponse)) {
+	wp.onComplete = fn
+}
+
 func (wp *WorkerPool) Start() {
 	logger.Get().Info("Starting worker pool", zap.Int("workers", wp.workers))
 	for i := range wp.partitions {
@@ -117,6 +143,17 @@ func (wp *WorkerPool) worker(id int) {
 				continue
 			}
 
+			if wp.isCancelled(aiResponse.GenerationID) {
+				wp.mu.Lock()
+				wp.messagesDropped++
+				wp.mu.Unlock()
+				logger.Get().Debug("Dropped chunk for cancelled generation",
+					zap.Int("worker_id", id),
+					zap.String("conversation_id", aiResponse.ConversationID),
+					zap.String("generation_id", aiResponse.GenerationID))
+				continue
+			}
+
 			logger.Get().Debug("Processing message",
 				zap.Int("worker_id", id),
 				zap.String("conversation_id", aiResponse.ConversationID))
@@ -124,6 +161,13 @@ func (wp *WorkerPool) worker(id int) {
 			// Process the message
 			sse.SendChunkToClient(aiResponse.ConversationID, string(job))
 
+			if aiResponse.LastMessage {
+				wp.markFinished(aiResponse.GenerationID)
+			}
+			if aiResponse.LastMessage && !aiResponse.Error && wp.onComplete != nil {
+				go wp.onComplete(aiResponse)
+			}
+
 			wp.mu.Lock()
 			wp.messagesProcessed++
 			wp.processingDuration += uint64(time.Since(startTime).Milliseconds())
@@ -137,6 +181,75 @@ func (wp *WorkerPool) worker(id int) {
 	}
 }
 
+// Cancel stops forwarding chunks for a generation and tells the connected client
+// that it was cancelled. Cancelling the same generation again, or one whose answer
+// already finished, is a no-op.
+func (wp *WorkerPool) Cancel(conversationID string, generationID string) {
+	if generationID == "" {
+		return
+	}
+
+	now := time.Now()
+
+	wp.cancelMu.Lock()
+	pruneExpired(wp.cancelled, now)
+	pruneExpired(wp.finished, now)
+	_, alreadyCancelled := wp.cancelled[generationID]
+	_, alreadyFinished := wp.finished[generationID]
+	if !alreadyFinished {
+		wp.cancelled[generationID] = now.Add(cancelledGenerationTTL)
+	}
+	wp.cancelMu.Unlock()
+
+	if alreadyFinished {
+		logger.Get().Debug("Ignoring cancel for finished generation",
+			zap.String("conversation_id", conversationID),
+			zap.String("generation_id", generationID))
+		return
+	}
+	if alreadyCancelled {
+		return
+	}
+
+	logger.Get().Info("Generation cancelled",
+		zap.String("conversation_id", conversationID),
+		zap.String("generation_id", generationID))
+	sse.CancelGeneration(conversationID, generationID)
+}
+
+func (wp *WorkerPool) markFinished(generationID string) {
+	if generationID == "" {
+		return
+	}
+
+	now := time.Now()
+
+	wp.cancelMu.Lock()
+	pruneExpired(wp.finished, now)
+	wp.finished[generationID] = now.Add(finishedGenerationTTL)
+	wp.cancelMu.Unlock()
+}
+
+func pruneExpired(generations map[string]time.Time, now time.Time) {
+	for id, expiresAt := range generations {
+		if now.After(expiresAt) {
+			delete(generations, id)
+		}
+	}
+}
+
+func (wp *WorkerPool) isCancelled(generationID string) bool {
+	if generationID == "" {
+		return false
+	}
+
+	wp.cancelMu.Lock()
+	defer wp.cancelMu.Unlock()
+
+	expiresAt, ok := wp.cancelled[generationID]
+	return ok && time.Now().Before(expiresAt)
+}
+
 // MetricsHandler returns the current metrics as JSON
 func (wp *WorkerPool) MetricsHandler(w http.ResponseWriter, r *http.Request) {
 	wp.mu.RLock()